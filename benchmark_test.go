@@ -91,3 +91,47 @@ func BenchmarkUnmarshal(b *testing.B) {
 		Unmarshal(data, &mixed)
 	}
 }
+
+func BenchmarkUnmarshal_MixedData_1000(b *testing.B) {
+	v := make([]mixedData, 1000)
+	for i := range v {
+		v[i] = mixedDataInstance
+	}
+	data, err := Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data = append([]byte(nil), data...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out []mixedData
+		Unmarshal(data, &out)
+	}
+}
+
+func BenchmarkMarshal_Parallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		m := NewMarshaler()
+		for pb.Next() {
+			m.Marshal(mixedDataInstance)
+		}
+	})
+}
+
+func BenchmarkUnmarshal_Parallel(b *testing.B) {
+	data, err := Marshal(mixedDataInstance)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data = append([]byte(nil), data...)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		u := NewUnmarshaler()
+		for pb.Next() {
+			var out mixedData
+			u.Unmarshal(data, &out)
+		}
+	})
+}
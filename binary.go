@@ -0,0 +1,228 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// usage is the representation of a number in its field, after the COBOL USAGE clause
+type usage int
+
+const (
+	// usageDisplay writes a number as text
+	usageDisplay usage = iota
+	// usageComp writes a number as a big-endian binary integer
+	usageComp
+	// usageComp3 writes a number as a packed decimal, two digits per byte and the sign in the last half-byte
+	usageComp3
+)
+
+// Sign half-bytes of packed decimals
+const (
+	comp3Positive = 0x0C
+	comp3Negative = 0x0D
+	comp3Unsigned = 0x0F
+)
+
+// binaryCodec returns the codec of a number field stored in bytes instead of text,
+// the width of such field is a number of bytes.
+func binaryCodec(u usage) codec {
+	if u == usageComp3 {
+		return codec{encode: encodeComp3, decode: decodeComp3}
+	}
+	return codec{encode: encodeComp, decode: decodeComp}
+}
+
+// isUnsigned reports whether a number field of kind k is written without sign
+func (o tagOptions) isUnsigned(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return o.unsigned
+}
+
+// encodeComp3 appends v as a packed decimal of f.width bytes
+func encodeComp3(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	var digits []byte
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		digits = strconv.AppendInt(nil, v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		digits = strconv.AppendUint(nil, v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		if x := v.Float(); math.IsNaN(x) || math.IsInf(x, 0) {
+			return fmt.Errorf("%v is not a finite number", x)
+		}
+		digits = appendImpliedFloat(nil, v.Float(), f.implied, v.Type().Bits())
+	}
+
+	sign := byte(comp3Positive)
+	if hasSign(digits) {
+		// -0 is positive
+		if digits[0] == '-' && len(bytes.Trim(digits[1:], "0")) > 0 {
+			sign = comp3Negative
+		}
+		digits = digits[1:]
+	}
+	if sign == comp3Negative && f.isUnsigned(v.Kind()) {
+		return fmt.Errorf("negative value %v in an unsigned field", v)
+	}
+	if f.isUnsigned(v.Kind()) {
+		sign = comp3Unsigned
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("invalid digit %q in %s", c, digits)
+		}
+	}
+
+	size := 2*f.width - 1
+	if len(digits) > size {
+		return fmt.Errorf("%s does not fit in %d digits", digits, size)
+	}
+
+	// the nibbles are the digits, right-aligned, then the sign
+	nibbles := make([]byte, 0, size+1)
+	for i := len(digits); i < size; i++ {
+		nibbles = append(nibbles, 0)
+	}
+	for _, c := range digits {
+		nibbles = append(nibbles, c-'0')
+	}
+	nibbles = append(nibbles, sign)
+	for i := 0; i < len(nibbles); i += 2 {
+		m.b = append(m.b, nibbles[i]<<4|nibbles[i+1])
+	}
+	return nil
+}
+
+// decodeComp3 parses data, a packed decimal; null bytes, written for nil pointers, leave the zero value.
+func decodeComp3(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	if len(bytes.Trim(data, "\x00")) == 0 {
+		return nil
+	}
+
+	digits := make([]byte, 0, 2*len(data))
+	for i, c := range data {
+		hi, lo := c>>4, c&0x0F
+		if hi > 9 || i < len(data)-1 && lo > 9 {
+			return fmt.Errorf("invalid packed decimal % X", data)
+		}
+		digits = append(digits, '0'+hi)
+		if i < len(data)-1 {
+			digits = append(digits, '0'+lo)
+		}
+	}
+
+	switch data[len(data)-1] & 0x0F {
+	case 0x0A, 0x0C, 0x0E, comp3Unsigned:
+	case 0x0B, comp3Negative:
+		digits = append([]byte{'-'}, digits...)
+	default:
+		return fmt.Errorf("invalid packed decimal sign % X", data)
+	}
+	return d.unmarshalBasicType(digits, f.tagOptions, v)
+}
+
+// encodeComp appends v as a big-endian integer of f.width bytes,
+// a float is scaled by its implied decimals and rounded.
+func encodeComp(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	bits := uint(8 * f.width)
+	unsigned := f.isUnsigned(v.Kind())
+
+	var n uint64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if !fitsInt(i, bits, unsigned) {
+			return fmt.Errorf("%d does not fit in %d bytes", i, f.width)
+		}
+		n = uint64(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = v.Uint()
+		if bits < 64 && n >= 1<<bits {
+			return fmt.Errorf("%d does not fit in %d bytes", n, f.width)
+		}
+	case reflect.Float32, reflect.Float64:
+		x := math.Round(v.Float() * math.Pow10(f.implied))
+		if math.IsNaN(x) || x < math.MinInt64 || x >= math.MaxInt64 || !fitsInt(int64(x), bits, unsigned) {
+			return fmt.Errorf("%v does not fit in %d bytes", v.Float(), f.width)
+		}
+		n = uint64(int64(x))
+	}
+
+	for shift := int(bits) - 8; shift >= 0; shift -= 8 {
+		m.b = append(m.b, byte(n>>uint(shift)))
+	}
+	return nil
+}
+
+// splitsRecord reports whether the record starting at start would be cut short by the Terminator
+// when it is read back, the bytes of binary fields may take any value.
+func (m *Marshaler) splitsRecord(start int) bool {
+	if len(m.Terminator) == 0 {
+		return false
+	}
+	record := m.b[start:]
+	if string(m.Terminator) == "\n" && bytes.HasSuffix(record, []byte("\r")) {
+		// \r\n ends a record as well
+		return true
+	}
+	// a match may begin in the record and end in the Terminator following it
+	return bytes.Index(append(record[:len(record):len(record)], m.Terminator...), m.Terminator) < len(record)
+}
+
+// fitsInt reports whether i is represented by an integer of the given bits
+func fitsInt(i int64, bits uint, unsigned bool) bool {
+	if unsigned {
+		return i >= 0 && (bits >= 64 || i < 1<<bits)
+	}
+	if bits >= 64 {
+		return true
+	}
+	return i >= -1<<(bits-1) && i < 1<<(bits-1)
+}
+
+// decodeComp parses data, a big-endian integer
+func decodeComp(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var n uint64
+	for _, c := range data {
+		n = n<<8 | uint64(c)
+	}
+	bits := uint(8 * len(data))
+	signed := !f.isUnsigned(v.Kind())
+	if signed && bits < 64 && n&(1<<(bits-1)) != 0 {
+		// sign extension
+		n |= ^uint64(0) << bits
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := int64(n)
+		if !signed && i < 0 || v.OverflowInt(i) {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		x := float64(n)
+		if signed {
+			x = float64(int64(n))
+		}
+		v.SetFloat(x / math.Pow10(f.implied))
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package fixedwidth
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type copybook struct {
+	ID      string  `fixed:"3"`
+	Amount  float64 `fixed:"4,comp3,implied=2"` // PIC S9(5)V99 COMP-3
+	Count   int     `fixed:"2,comp3"`
+	Units   uint16  `fixed:"2,comp3"`
+	Balance int32   `fixed:"4,comp"`
+	Flags   uint8   `fixed:"1,comp"`
+	Rate    float64 `fixed:"2,comp,implied=1"`
+	Code    int     `fixed:"2,comp,unsigned"`
+	Name    string  `fixed:"4"`
+}
+
+func TestBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		v    copybook
+		data string
+	}{
+		{
+			name: "positive",
+			v: copybook{
+				ID: "Ñ01", Amount: 12345.67, Count: 5, Units: 120, Balance: 258,
+				Flags: 255, Rate: 2.5, Code: 65535, Name: "Huy",
+			},
+			data: "Ñ01\x12\x34\x56\x7C\x00\x5C\x12\x0F\x00\x00\x01\x02\xFF\x00\x19\xFF\xFFHuy ",
+		},
+		{
+			name: "negative",
+			v:    copybook{ID: "002", Amount: -0.01, Count: -123, Balance: -2, Rate: -0.1},
+			data: "002\x00\x00\x00\x1D\x12\x3D\x00\x0F\xFF\xFF\xFF\xFE\x00\xFF\xFF\x00\x00    ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = % X, want % X", got, tt.data)
+			}
+
+			var back copybook
+			if err := Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, tt.v)
+			}
+		})
+	}
+}
+
+func TestMarshal_BinaryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    copybook
+	}{
+		{name: "too many digits", v: copybook{Count: 1234}},
+		{name: "comp overflow", v: copybook{Rate: 3276.8}},
+		{name: "negative unsigned", v: copybook{Code: -1}},
+		{name: "comp3 NaN", v: copybook{Amount: math.NaN()}},
+		{name: "comp3 infinity", v: copybook{Amount: math.Inf(1)}},
+		{name: "comp3 negative infinity", v: copybook{Amount: math.Inf(-1)}},
+		{name: "comp NaN", v: copybook{Rate: math.NaN()}},
+		{name: "comp infinity", v: copybook{Rate: math.Inf(-1)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Marshal(tt.v); err == nil {
+				t.Error("Marshal() expected error")
+			}
+		})
+	}
+}
+
+func TestUnmarshal_BinaryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid digit", data: "001\x12\xA4\x56\x7C" + strings.Repeat("\x00", 13) + "    "},
+		{name: "invalid sign", data: "001\x12\x34\x56\x71" + strings.Repeat("\x00", 13) + "    "},
+		{name: "unsigned overflow", data: "001\x00\x00\x00\x0C\x00\x0C\x00\x0D" + strings.Repeat("\x00", 9) + "    "},
+		{name: "short record", data: "001\x00\x00\x00\x0C\x00\x0C\x00\x0C\x00\x00\x00\x0A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got copybook
+			if err := Unmarshal([]byte(tt.data), &got); err == nil {
+				t.Error("Unmarshal() expected error")
+			}
+		})
+	}
+}
+
+func TestBinary_NilPointer(t *testing.T) {
+	type record struct {
+		Amount *int64 `fixed:"3,comp3"`
+		Count  *int16 `fixed:"2,comp"`
+	}
+
+	got, err := Marshal(record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x00\x00\x00\x00\x00"; string(got) != want {
+		t.Errorf("Marshal() got = % X, want % X", got, want)
+	}
+
+	var back record
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Amount == nil || *back.Amount != 0 || back.Count == nil || *back.Count != 0 {
+		t.Errorf("Unmarshal() got = %+v, want zero values", back)
+	}
+}
+
+func TestBinary_Terminator(t *testing.T) {
+	type record struct {
+		ID    string `fixed:"3"`
+		Count int32  `fixed:"4,comp"`
+		Name  string `fixed:"2"`
+	}
+	records := []record{{ID: "abc", Count: 10, Name: "zz"}, {ID: "def", Count: 2, Name: "yy"}}
+
+	// 10 is written as a new line character
+	if _, err := Marshal(records); err == nil {
+		t.Error("Marshal() expected error")
+	}
+	var buf strings.Builder
+	e := NewEncoder(&buf)
+	if err := e.Encode(records); err == nil {
+		t.Error("Encode() expected error")
+	}
+	e.Terminator = []byte("\r\n")
+	if err := e.Encode(record{Count: 0x0D0A}); err == nil {
+		t.Error("Encode() expected error")
+	}
+
+	m := NewMarshaler()
+	m.Terminator = nil
+	data, err := m.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewUnmarshaler()
+	u.Terminator = nil
+	var got []record
+	if err := u.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", got, records)
+	}
+
+	// the first record is cut at the new line character
+	var split []record
+	err = Unmarshal([]byte("abc\x00\x00\x00\x0Azz\ndef\x00\x00\x00\x02yy"), &split)
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) || recordErr.Line != 1 {
+		t.Errorf("Unmarshal() error = %v, want a RecordError of line 1", err)
+	}
+}
@@ -0,0 +1,80 @@
+package fixedwidth
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Charset is a single-byte character set, such as EBCDIC.
+// Each character takes one byte, so the width of a field is its number of bytes.
+type Charset struct {
+	name  string
+	runes *[256]rune
+	bytes map[rune]byte
+}
+
+// Single-byte character sets of the Marshaler and Unmarshaler, a nil Charset means UTF-8
+var (
+	// CP037 is EBCDIC code page 037, used on IBM mainframes in the US and Canada
+	CP037 = newCharset("CP037", &cp037Runes)
+
+	// CP1047 is EBCDIC code page 1047, used by z/OS Unix System Services
+	CP1047 = newCharset("CP1047", &cp1047Runes)
+
+	// Latin1 is ISO 8859-1
+	Latin1 = newCharset("ISO-8859-1", latin1Runes())
+
+	// Windows1252 is Windows code page 1252, a superset of ISO 8859-1 printable characters
+	Windows1252 = newCharset("Windows-1252", &windows1252Runes)
+)
+
+func newCharset(name string, runes *[256]rune) *Charset {
+	c := &Charset{name: name, runes: runes, bytes: make(map[rune]byte, len(runes))}
+	for b, r := range runes {
+		c.bytes[r] = byte(b)
+	}
+	return c
+}
+
+func latin1Runes() *[256]rune {
+	var runes [256]rune
+	for b := range runes {
+		runes[b] = rune(b)
+	}
+	return &runes
+}
+
+// String returns the name of the character set
+func (c *Charset) String() string {
+	return c.name
+}
+
+// decode returns the UTF-8 encoding of b
+func (c *Charset) decode(b []byte) []byte {
+	s := make([]byte, 0, len(b))
+	for _, x := range b {
+		if r := c.runes[x]; r < utf8.RuneSelf {
+			s = append(s, byte(r))
+		} else {
+			s = appendRune(s, r)
+		}
+	}
+	return s
+}
+
+// encode converts the UTF-8 text src to the character set into dst, returning the number of bytes written.
+// dst can be the beginning of src as each character takes at most as many bytes.
+func (c *Charset) encode(dst, src []byte) (int, error) {
+	n := 0
+	for len(src) > 0 {
+		r, s := utf8.DecodeRune(src)
+		b, ok := c.bytes[r]
+		if !ok {
+			return n, fmt.Errorf("character %q cannot be encoded in %s", r, c.name)
+		}
+		dst[n] = b
+		n++
+		src = src[s:]
+	}
+	return n, nil
+}
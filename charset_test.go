@@ -0,0 +1,120 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type mainframeRecord struct {
+	Name    string  `fixed:"6"`
+	Amount  int     `fixed:"5,right,pad=0"`
+	Balance float64 `fixed:"3,comp3,implied=2"`
+	City    string  `fixed:"4"`
+}
+
+func TestCharset(t *testing.T) {
+	v := mainframeRecord{Name: "José", Amount: -42, Balance: 123.45, City: "Köln"}
+
+	tests := []struct {
+		charset *Charset
+		data    string
+	}{
+		{CP037, "\xD1\x96\xA2\x51\x40\x40" + "\x60\xF0\xF0\xF4\xF2" + "\x12\x34\x5C" + "\xD2\xCC\x93\x95"},
+		{CP1047, "\xD1\x96\xA2\x51\x40\x40" + "\x60\xF0\xF0\xF4\xF2" + "\x12\x34\x5C" + "\xD2\xCC\x93\x95"},
+		{Latin1, "Jos\xE9  " + "-0042" + "\x12\x34\x5C" + "K\xF6ln"},
+		{Windows1252, "Jos\xE9  " + "-0042" + "\x12\x34\x5C" + "K\xF6ln"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.charset.String(), func(t *testing.T) {
+			m := NewMarshaler()
+			m.Charset = tt.charset
+			got, err := m.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = % X, want % X", got, tt.data)
+			}
+
+			u := NewUnmarshaler()
+			u.Charset = tt.charset
+			var back mainframeRecord
+			if err := u.Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, v) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, v)
+			}
+		})
+	}
+}
+
+func TestCharset_Tables(t *testing.T) {
+	tests := []struct {
+		charset *Charset
+		text    string
+		data    string
+	}{
+		{CP037, "[a-z]^¬|", "\xBA\x81\x60\xA9\xBB\xB0\x5F\x4F"},
+		{CP1047, "[a-z]^¬|", "\xAD\x81\x60\xA9\xBD\x5F\xB0\x4F"},
+		{Windows1252, "€“”ÿ", "\x80\x93\x94\xFF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.charset.String(), func(t *testing.T) {
+			b := []byte(tt.text)
+			n, err := tt.charset.encode(b, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b[:n]) != tt.data {
+				t.Errorf("encode() got = % X, want % X", b[:n], tt.data)
+			}
+			if got := tt.charset.decode([]byte(tt.data)); string(got) != tt.text {
+				t.Errorf("decode() got = %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestMarshal_CharsetError(t *testing.T) {
+	m := NewMarshaler()
+	m.Charset = Latin1
+	_, err := m.Marshal(mainframeRecord{Name: "Huy", City: "Nội"})
+	if err == nil {
+		t.Fatal("Marshal() expected error for a character out of the character set")
+	}
+
+	m.Charset = CP037
+	if _, err := m.Marshal(mainframeRecord{Name: "€"}); err == nil || errors.Unwrap(err) == nil {
+		t.Errorf("Marshal() got error %v, want a wrapped error", err)
+	}
+}
+
+func TestStream_Charset(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.Charset = CP037
+	records := []mainframeRecord{{Name: "Ana", Amount: 1}, {Name: "Bé", Amount: 2}}
+	if err := e.Encode(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(&buf)
+	d.Charset = CP037
+	for _, want := range records {
+		var got mainframeRecord
+		if err := d.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Decode() got = %+v, want %+v", got, want)
+		}
+	}
+}
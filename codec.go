@@ -0,0 +1,216 @@
+package fixedwidth
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	marshalerType       = reflect.TypeOf((*FixedWidthMarshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*FixedWidthUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// codec converts a field value to its fixed-width representation and back
+type codec struct {
+	// encode appends the representation of v to the underlying slice of bytes of m
+	encode func(m *Marshaler, f *fieldPlan, v reflect.Value) error
+
+	// decode parses data, the runes of the field, and stores the result in v
+	decode func(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error
+}
+
+// codecFor returns the codec of a leaf field of type t, with the options of its fixed tag.
+//
+// Binary numbers set by the comp and comp3 options come first.
+// FixedWidthMarshaler and FixedWidthUnmarshaler are checked first,
+// with either value or pointer receivers, then time.Time and the basic kinds.
+// encoding.TextMarshaler and encoding.TextUnmarshaler are the fallback
+// for the other types, a named basic type keeps its basic encoding even if it implements them.
+func codecFor(t reflect.Type, opts tagOptions) (codec, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := codecFor(t.Elem(), opts)
+		if err != nil {
+			return codec{}, err
+		}
+		return pointerCodec(elem), nil
+	case reflect.Interface:
+		return codec{encode: encodeInterface, decode: decodeInterface}, nil
+	}
+
+	if opts.usage != usageDisplay && isNumeric(t) {
+		return binaryCodec(opts.usage), nil
+	}
+
+	var c codec
+	if implements(t, marshalerType) {
+		c.encode = encodeMarshaler
+	}
+	if implements(t, unmarshalerType) {
+		c.decode = decodeUnmarshaler
+	}
+
+	if t == timeType {
+		if c.encode == nil {
+			c.encode = encodeTime
+		}
+		if c.decode == nil {
+			c.decode = decodeTime
+		}
+	}
+
+	if isBasicType(t.Kind()) {
+		if c.encode == nil {
+			c.encode = encodeBasicType
+		}
+		if c.decode == nil {
+			c.decode = decodeBasicType
+		}
+	}
+
+	if c.encode == nil && implements(t, textMarshalerType) {
+		c.encode = encodeTextMarshaler
+	}
+	if c.decode == nil && implements(t, textUnmarshalerType) {
+		c.decode = decodeTextUnmarshaler
+	}
+
+	if c.encode == nil && c.decode == nil {
+		return codec{}, fmt.Errorf("unsupported type %s", t)
+	}
+	if c.encode == nil {
+		c.encode = func(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+			return fmt.Errorf("type %s implements neither FixedWidthMarshaler nor encoding.TextMarshaler", t)
+		}
+	}
+	if c.decode == nil {
+		c.decode = func(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+			return fmt.Errorf("type %s implements neither FixedWidthUnmarshaler nor encoding.TextUnmarshaler", t)
+		}
+	}
+	return c, nil
+}
+
+func pointerCodec(elem codec) codec {
+	return codec{
+		encode: func(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+			if v.IsNil() {
+				return nil
+			}
+			return elem.encode(m, f, v.Elem())
+		},
+		decode: func(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+			newValue := reflect.New(v.Type().Elem())
+			err := elem.decode(d, f, data, newValue.Elem())
+			if err != nil {
+				return err
+			}
+			v.Set(newValue)
+			return nil
+		},
+	}
+}
+
+// implements reports whether t or a pointer to t implements interface it
+func implements(t, it reflect.Type) bool {
+	return t.Implements(it) || reflect.PtrTo(t).Implements(it)
+}
+
+// hasCustomCodec reports whether the values of t are converted by their own methods,
+// such struct is a leaf field instead of being flattened.
+func hasCustomCodec(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == timeType || implements(t, marshalerType) || implements(t, unmarshalerType) ||
+		implements(t, textMarshalerType) || implements(t, textUnmarshalerType)
+}
+
+// addressable returns v or, if v cannot be addressed, a copy of v which can.
+// It makes the methods with pointer receivers callable.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// asInterface returns the value of v as it, looking at the pointer receivers as well
+func asInterface(v reflect.Value, it reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(it) {
+		return v.Interface(), true
+	}
+	if reflect.PtrTo(v.Type()).Implements(it) {
+		return addressable(v).Addr().Interface(), true
+	}
+	return nil, false
+}
+
+func encodeMarshaler(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	i, _ := asInterface(v, marshalerType)
+	b, err := i.(FixedWidthMarshaler).MarshalFixedWidth()
+	if err != nil {
+		return err
+	}
+	m.b = append(m.b, b...)
+	return nil
+}
+
+func decodeUnmarshaler(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	if !v.CanAddr() {
+		return errors.New("cannot unmarshal into an unaddressable value")
+	}
+
+	i, _ := asInterface(v, unmarshalerType)
+	return i.(FixedWidthUnmarshaler).UnmarshalFixedWidth(data)
+}
+
+func encodeTextMarshaler(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	i, _ := asInterface(v, textMarshalerType)
+	b, err := i.(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return err
+	}
+	m.b = append(m.b, b...)
+	return nil
+}
+
+// decodeTextUnmarshaler passes the field without padding to UnmarshalText,
+// a blank field leaves the zero value.
+func decodeTextUnmarshaler(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	data = d.removeFieldPadding(f, data)
+	if len(data) == 0 {
+		return nil
+	}
+	if !v.CanAddr() {
+		return errors.New("cannot unmarshal into an unaddressable value")
+	}
+
+	i, _ := asInterface(v, textUnmarshalerType)
+	return i.(encoding.TextUnmarshaler).UnmarshalText(data)
+}
+
+// boolTokens returns the tokens of a bool field, the options of the fixed tag take precedence
+// over the defaults of the Marshaler or Unmarshaler, then "1" and "0".
+func boolTokens(fieldTrue, fieldFalse, defaultTrue, defaultFalse string) (string, string) {
+	trueValue, falseValue := "1", "0"
+	if defaultTrue != "" {
+		trueValue = defaultTrue
+	}
+	if defaultFalse != "" {
+		falseValue = defaultFalse
+	}
+	if fieldTrue != "" {
+		trueValue = fieldTrue
+	}
+	if fieldFalse != "" {
+		falseValue = fieldFalse
+	}
+	return trueValue, falseValue
+}
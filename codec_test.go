@@ -0,0 +1,293 @@
+package fixedwidth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// accountNumber is written with dashes every 4 digits
+type accountNumber string
+
+func (a accountNumber) MarshalFixedWidth() ([]byte, error) {
+	var parts []string
+	for s := string(a); len(s) > 0; {
+		n := 4
+		if len(s) < n {
+			n = len(s)
+		}
+		parts = append(parts, s[:n])
+		s = s[n:]
+	}
+	return []byte(strings.Join(parts, "-")), nil
+}
+
+func (a *accountNumber) UnmarshalFixedWidth(data []byte) error {
+	*a = accountNumber(strings.Replace(strings.TrimSpace(string(data)), "-", "", -1))
+	return nil
+}
+
+// money is written in cents, zero-filled
+type money struct {
+	Units, Cents int64
+}
+
+func (m *money) MarshalFixedWidth() ([]byte, error) {
+	if m.Units < 0 {
+		return nil, errors.New("negative amount")
+	}
+	return []byte(fmt.Sprintf("%08d", m.Units*100+m.Cents)), nil
+}
+
+func (m *money) UnmarshalFixedWidth(data []byte) error {
+	v, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	m.Units, m.Cents = v/100, v%100
+	return nil
+}
+
+type transfer struct {
+	From    accountNumber `fixed:"14"`
+	To      accountNumber `fixed:"9"`
+	Amount  money         `fixed:"8"`
+	Fee     *money        `fixed:"8"`
+	Comment interface{}   `fixed:"10"`
+}
+
+func TestMarshal_FixedWidthMarshaler(t *testing.T) {
+	v := transfer{
+		From:    "123456789012",
+		To:      "98765432",
+		Amount:  money{Units: 12, Cents: 34},
+		Fee:     &money{Cents: 50},
+		Comment: accountNumber("12345"),
+	}
+	want := "1234-5678-90129876-543200001234000000501234-5    "
+
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+
+	v.Fee = &money{Units: -1}
+	if _, err := Marshal(v); err == nil || !strings.Contains(err.Error(), "Fee") {
+		t.Errorf("Marshal() error = %v, want error of field Fee", err)
+	}
+}
+
+func TestUnmarshal_FixedWidthUnmarshaler(t *testing.T) {
+	want := transfer{
+		From:    "123456789012",
+		To:      "98765432",
+		Amount:  money{Units: 12, Cents: 34},
+		Fee:     &money{Cents: 50},
+		Comment: "1234-5",
+	}
+
+	var got transfer
+	err := Unmarshal([]byte("1234-5678-90129876-543200001234000000501234-5    "), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", got, want)
+	}
+
+	err = Unmarshal([]byte("1234-5678-90129876-54320000x234"), &got)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "Amount" {
+		t.Errorf("Unmarshal() error = %v, want error of field Amount", err)
+	}
+}
+
+type marshalOnly struct {
+	Name string
+}
+
+func (m marshalOnly) MarshalFixedWidth() ([]byte, error) {
+	return []byte(strings.ToUpper(m.Name)), nil
+}
+
+func TestCodec_OneSide(t *testing.T) {
+	type record struct {
+		M marshalOnly `fixed:"5"`
+	}
+
+	got, err := Marshal(record{M: marshalOnly{Name: "huy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "HUY  " {
+		t.Errorf("Marshal() got = %q, want %q", got, "HUY  ")
+	}
+
+	var r record
+	if err := Unmarshal(got, &r); err == nil {
+		t.Error("Unmarshal() expected error for a type without FixedWidthUnmarshaler")
+	}
+}
+
+// version implements encoding.TextMarshaler, it is not flattened like other structs
+type version struct {
+	Major, Minor int
+}
+
+func (v version) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("v%d.%d", v.Major, v.Minor)), nil
+}
+
+func (v *version) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "v%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+// level implements both FixedWidthMarshaler and encoding.TextMarshaler
+type level struct {
+	N int
+}
+
+func (l level) MarshalFixedWidth() ([]byte, error) {
+	return []byte("L" + strconv.Itoa(l.N)), nil
+}
+
+func (l *level) UnmarshalFixedWidth(data []byte) error {
+	n, err := strconv.Atoi(strings.TrimSpace(string(data))[1:])
+	l.N = n
+	return err
+}
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	return errors.New("UnmarshalText must not be called")
+}
+
+// color is a basic kind, it keeps the numeric encoding even though it implements encoding.TextMarshaler
+type color int
+
+func (c color) MarshalText() ([]byte, error) {
+	return []byte([]string{"red", "green"}[c]), nil
+}
+
+func (c *color) UnmarshalText(text []byte) error {
+	return errors.New("UnmarshalText must not be called")
+}
+
+type textRecord struct {
+	IP      net.IP   `fixed:"15"`
+	Version version  `fixed:"6"`
+	Level   level    `fixed:"4"`
+	Color   color    `fixed:"2"`
+	Backup  *version `fixed:"6"`
+}
+
+func TestTextMarshaler(t *testing.T) {
+	v := textRecord{
+		IP:      net.IPv4(192, 168, 1, 10),
+		Version: version{Major: 1, Minor: 12},
+		Level:   level{N: 3},
+		Color:   1,
+	}
+	data := "192.168.1.10   v1.12 L3  1       "
+
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("Marshal() got = %q, want %q", got, data)
+	}
+
+	var back textRecord
+	if err := Unmarshal([]byte(data), &back); err != nil {
+		t.Fatal(err)
+	}
+	// like other pointer fields, Backup is allocated even if it is blank
+	want := v
+	want.Backup = &version{}
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", back, want)
+	}
+
+	err = Unmarshal([]byte("192.168.1.1000 v1.12 L3  1 "), &back)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "IP" {
+		t.Errorf("Unmarshal() error = %v, want error of field IP", err)
+	}
+}
+
+type flags struct {
+	Active  bool  `fixed:"1"`
+	Member  bool  `fixed:"3,true=YES,false=NO"`
+	Deleted *bool `fixed:"1,true=Y,false=N"`
+}
+
+func TestBool(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name       string
+		trueValue  string
+		falseValue string
+		v          flags
+		data       string
+	}{
+		{
+			name: "default tokens",
+			v:    flags{Active: true, Deleted: &yes},
+			data: "1NO Y",
+		},
+		{
+			name:       "marshaler tokens",
+			trueValue:  "T",
+			falseValue: "F",
+			v:          flags{Member: true, Deleted: &yes},
+			data:       "FYESY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.TrueValue, m.FalseValue = tt.trueValue, tt.falseValue
+			got, err := m.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.data)
+			}
+
+			u := NewUnmarshaler()
+			u.TrueValue, u.FalseValue = tt.trueValue, tt.falseValue
+			var back flags
+			if err := u.Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, tt.v)
+			}
+		})
+	}
+
+	var v flags
+	if err := Unmarshal([]byte("   "), &v); err != nil || v.Active || v.Member {
+		t.Errorf("Unmarshal() blank got = %+v, %v, want false values", v, err)
+	}
+
+	err := Unmarshal([]byte("1MAYY"), &v)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "Member" {
+		t.Errorf("Unmarshal() got error %v, want a FieldError of Member", err)
+	}
+}
@@ -0,0 +1,229 @@
+package fixedwidth
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// controlKind is the total computed in a control field of a trailer record
+type controlKind int
+
+const (
+	controlNone controlKind = iota
+
+	// controlCount counts the records, or the records of the given codes
+	controlCount
+
+	// controlSum sums a field of the records
+	controlSum
+
+	// controlHash sums a field of the records, keeping the digits which fit in the control field
+	controlHash
+)
+
+// controlScopes holds the records controlled by trailers, one list per enclosing group.
+// The outermost list holds the records of the whole data.
+type controlScopes [][]reflect.Value
+
+func (s *controlScopes) push() {
+	*s = append(*s, nil)
+}
+
+func (s *controlScopes) pop() {
+	*s = (*s)[:len(*s)-1]
+}
+
+// drop removes the records added to the outermost group after its first n records
+func (s controlScopes) drop(n int) {
+	if len(s) > 0 {
+		s[0] = s[0][:n]
+	}
+}
+
+// size returns the number of records of the outermost group
+func (s controlScopes) size() int {
+	if len(s) == 0 {
+		return 0
+	}
+	return len(s[0])
+}
+
+// add appends the record v to every enclosing group.
+// An addressable record is copied, its caller may reuse it, such as the value a Decoder decodes into.
+func (s controlScopes) add(v reflect.Value) {
+	if len(s) == 0 {
+		return
+	}
+	if v.CanAddr() {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+	for i := range s {
+		s[i] = append(s[i], v)
+	}
+}
+
+// innermost returns the records of the innermost group
+func (s controlScopes) innermost() []reflect.Value {
+	return s[len(s)-1]
+}
+
+// controlled reports whether a registered record type has control fields,
+// the records of a stream are only kept for them.
+func (r *Registry) controlled() bool {
+	for t := range r.codes {
+		if p, err := planFor(t); err == nil && p.controls {
+			return true
+		}
+	}
+	return false
+}
+
+// controlTotal computes the value of the control field f from the records of its group.
+// The fields which are control totals themselves, such as the sum of a batch trailer, are not summed.
+func (r *Registry) controlTotal(f *fieldPlan, records []reflect.Value) (int64, error) {
+	var codes []string
+	if f.control == controlCount && f.controlArg != "" {
+		codes = strings.Split(f.controlArg, "|")
+	}
+
+	var total int64
+	for _, record := range records {
+		if f.control == controlCount {
+			if codes == nil || containsString(codes, r.codes[record.Type()]) {
+				total++
+			}
+			continue
+		}
+
+		p, err := planFor(record.Type())
+		if err != nil {
+			return 0, err
+		}
+		source := p.field(f.controlArg)
+		if source == nil || source.control != controlNone {
+			continue
+		}
+		v := fieldByIndex(record, source.index)
+		if !v.IsValid() {
+			continue
+		}
+		n, err := controlValue(v)
+		if err != nil {
+			return 0, fmt.Errorf("field %s: %v", f.name, err)
+		}
+		total += n
+	}
+
+	if f.control == controlHash && f.width < 19 {
+		mod := int64(1)
+		for i := 0; i < f.width; i++ {
+			mod *= 10
+		}
+		total %= mod
+	}
+	return total, nil
+}
+
+// field returns the field of the plan with the given path, nil if there is none
+func (p *typePlan) field(name string) *fieldPlan {
+	for _, f := range p.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// controlValue returns the value of an integer field, or of a string of digits, nil pointers are zero
+func controlValue(v reflect.Value) (int64, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.String:
+		s := strings.TrimSpace(v.String())
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.ParseInt(s, 10, 64)
+	}
+	return 0, fmt.Errorf("cannot total a %s", v.Type())
+}
+
+// fillControls returns a copy of the record v which control fields are computed from the records of its group
+func (m *Marshaler) fillControls(v reflect.Value) (reflect.Value, error) {
+	p, err := planFor(v.Type())
+	if err != nil || !p.controls {
+		return v, err
+	}
+
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	for _, f := range p.fields {
+		if f.control == controlNone {
+			continue
+		}
+		total, err := m.Registry.controlTotal(f, m.scopes.innermost())
+		if err != nil {
+			return v, err
+		}
+		fv, err := fieldByIndexAlloc(c, f.index)
+		if err != nil {
+			return v, err
+		}
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		if fv.Kind() >= reflect.Uint && fv.Kind() <= reflect.Uint64 {
+			fv.SetUint(uint64(total))
+		} else {
+			fv.SetInt(total)
+		}
+	}
+	return c, nil
+}
+
+// checkControls verifies the control fields of the decoded record v against the records of its group
+func (d *decodeState) checkControls(v reflect.Value) error {
+	p, err := planFor(v.Type())
+	if err != nil || !p.controls {
+		return err
+	}
+
+	for _, f := range p.fields {
+		if f.control == controlNone {
+			continue
+		}
+		expected, err := d.Registry.controlTotal(f, d.scopes.innermost())
+		if err != nil {
+			return &RecordError{Line: d.line, Err: err}
+		}
+		actual, _ := controlValue(fieldByIndex(v, f.index))
+		if actual != expected {
+			return &ControlTotalError{Line: d.line, Field: f.name, Expected: expected, Actual: actual}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package fixedwidth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// docWithoutTotals returns docValue with its control fields cleared
+func docWithoutTotals() docFile {
+	f := docValue
+	f.Batches = append([]docBatch(nil), docValue.Batches...)
+	for i := range f.Batches {
+		f.Batches[i].Control = docBatchControl{Code: "8"}
+	}
+	f.Trailer = &docFileControl{Code: "9"}
+	return f
+}
+
+func TestControlTotals(t *testing.T) {
+	r := newRegistry(t, 1, 1, docTypes)
+
+	m := NewMarshaler()
+	m.Registry = r
+	file := docWithoutTotals()
+	got, err := m.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != docData {
+		t.Errorf("Marshal() got = %q, want %q", got, docData)
+	}
+	if !reflect.DeepEqual(file, docWithoutTotals()) {
+		t.Error("Marshal() modified its input")
+	}
+
+	u := NewUnmarshaler()
+	u.Registry = r
+	var decoded docFile
+	if err := u.Unmarshal([]byte(docData), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, docValue) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", decoded, docValue)
+	}
+
+	// flat slices are controlled as a whole
+	records := []interface{}{
+		docBatchHeader{Code: "5", Batch: 1},
+		docEntry{Code: "6", Routing: "0012", Amount: 150},
+		docBatchControl{},
+	}
+	got, err = m.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "5001\n6001200150\n800112000150"; string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+}
+
+func TestControlTotals_Errors(t *testing.T) {
+	r := newRegistry(t, 1, 1, docTypes)
+	u := NewUnmarshaler()
+	u.Registry = r
+
+	tests := []struct {
+		name string
+		data string
+		want ControlTotalError
+	}{
+		{
+			name: "batch amount",
+			data: "1ACME  \n5001\n6001200150\n6009900025\n800211000176\n90010050000175",
+			want: ControlTotalError{Line: 5, Field: "Amount", Expected: 175, Actual: 176},
+		},
+		{
+			name: "batch count",
+			data: "1ACME  \n5001\n6001200150\n800212000150\n90010040000150",
+			want: ControlTotalError{Line: 4, Field: "Count", Expected: 1, Actual: 2},
+		},
+		{
+			name: "hash",
+			data: "1ACME  \n5001\n6001200150\n800113000150\n90010040000150",
+			want: ControlTotalError{Line: 4, Field: "Hash", Expected: 12, Actual: 13},
+		},
+		{
+			name: "record count",
+			data: "1ACME  \n5001\n6001200150\n800112000150\n90010050000150",
+			want: ControlTotalError{Line: 5, Field: "Records", Expected: 4, Actual: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file docFile
+			err := u.Unmarshal([]byte(tt.data), &file)
+			var controlErr *ControlTotalError
+			if !errors.As(err, &controlErr) || *controlErr != tt.want {
+				t.Errorf("Unmarshal() got error %v, want %v", err, &tt.want)
+			}
+		})
+	}
+
+	err := u.UnmarshalEach([]byte("5001\n6001200150\n800112000151"), func(interface{}) error { return nil })
+	if !errors.As(err, new(*ControlTotalError)) {
+		t.Errorf("UnmarshalEach() got error %v, want a ControlTotalError", err)
+	}
+
+	u.ContinueOnError = true
+	var records []interface{}
+	err = u.Unmarshal([]byte("5001\n6001200150\n800112000151\n6009900025"), &records)
+	var errs ErrorList
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("Unmarshal() got error %v, want 1 error", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("Unmarshal() got %d records, want 3", len(records))
+	}
+
+	m := NewMarshaler()
+	m.Registry = r
+	if _, err := m.Marshal([]interface{}{docEntry{Routing: "12x"}, docBatchControl{}}); err == nil {
+		t.Error("Marshal() expected error for a hash of letters")
+	}
+}
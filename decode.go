@@ -1,22 +1,84 @@
 package fixedwidth
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 	"unicode/utf8"
 )
 
 // Unmarshaler is the place fixed-width decoding happen
 type Unmarshaler struct {
-	tag
+	// Pad is the character removed around the values of fields,
+	// the pad option of the fixed tag overrides it for a single field.
+	// The zero value means a space.
+	Pad rune
+
+	// TimeLayout is the layout of time.Time fields, see time.Parse.
+	// The time option of the fixed tag overrides it for a single field.
+	// The zero value means time.RFC3339.
+	TimeLayout string
+
+	// TimeLocation is the location of times without time zone information.
+	// The zero value means UTC.
+	TimeLocation *time.Location
+
+	// TrueValue and FalseValue are the tokens of bool fields,
+	// the true and false options of the fixed tag override them for a single field.
+	// The zero values mean "1" and "0". A blank field is false.
+	TrueValue, FalseValue string
+
+	// Charset is the single-byte character set of the text fields,
+	// they are converted to UTF-8 before they are parsed.
+	// The zero value means UTF-8.
+	Charset *Charset
+
+	// WidthMode is the unit of the width of fields.
+	// It is ignored when Charset is set, every character is then a byte.
+	WidthMode WidthMode
+
+	// Registry, if it is set, holds the record types of the data.
+	// Records decoded into an interface, such as the elements of a []interface{},
+	// are then values of the type registered for their code.
+	Registry *Registry
+
+	// ContinueOnError makes Unmarshal skip the records which cannot be decoded into a slice,
+	// instead of stopping at the first one. The decoded records are kept
+	// and the failures are returned as an ErrorList.
+	ContinueOnError bool
+
+	// MaxErrors is the number of failed records after which Unmarshal stops
+	// even if ContinueOnError is set. Zero means no limit.
+	MaxErrors int
+
+	// Terminator separates the records, e.g. "\r\n". It is matched as is, before conversion from the Charset.
+	// NewUnmarshaler sets it to a new line character (\n), records ending with \r\n are then accepted as well.
+	// A Terminator at the end of the data is ignored.
+	// If it is empty, records are read by length: the width of the struct they are decoded into,
+	// or of the type registered for their code.
+	Terminator []byte
+
+	// SkipBlankLines makes Unmarshal and the Decoder skip the empty records, such as blank lines.
+	// They are still counted in the line numbers.
+	SkipBlankLines bool
 }
 
 // NewUnmarshaler create new Unmarshaler
 func NewUnmarshaler() Unmarshaler {
-	return Unmarshaler{}
+	return Unmarshaler{Terminator: []byte("\n")}
+}
+
+// decodeState holds the state of a single Unmarshal call
+type decodeState struct {
+	Unmarshaler
+
+	// line is the number of the record being decoded, starting from 1
+	line int
+
+	// scopes holds the records decoded in every enclosing group, they are controlled by trailer records
+	scopes controlScopes
 }
 
 // Unmarshal decodes fixed-width encoding data to model,
@@ -24,171 +86,390 @@ func NewUnmarshaler() Unmarshaler {
 func (m Unmarshaler) Unmarshal(data []byte, model interface{}) error {
 	modelType := reflect.TypeOf(model)
 
-	if modelType.Kind() != reflect.Ptr {
+	if modelType == nil || modelType.Kind() != reflect.Ptr {
 		return errors.New("the model must be a pointer")
 	}
 
-	_, err := m.unmarshal(data, reflect.ValueOf(model).Elem(), reflect.TypeOf(model).Elem())
-	return err
+	d := &decodeState{Unmarshaler: m, line: 1}
+	elemType := modelType.Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Slice {
+		// a single record may end with the Terminator
+		data = m.trimTerminator(data)
+	}
+	return d.unmarshal(data, reflect.ValueOf(model).Elem())
 }
 
-func (m Unmarshaler) unmarshal(data []byte, modelValue reflect.Value, modelType reflect.Type) (int, error) {
+func (d *decodeState) unmarshal(data []byte, modelValue reflect.Value) error {
+	modelType := modelValue.Type()
 	if isBasicType(modelType.Kind()) {
-		return m.unmarshalBasicType(data, modelValue)
+		if d.Charset != nil {
+			data = d.Charset.decode(data)
+		}
+		err := d.unmarshalBasicType(removePadding(data, alignLeft, d.padRune(0)), tagOptions{}, modelValue)
+		if err != nil {
+			return &RecordError{Line: d.line, Err: err}
+		}
+		return nil
 	}
 
 	switch modelType.Kind() {
 	case reflect.Struct:
-		return m.unmarshalStruct(data, modelValue)
+		if d.Registry != nil {
+			p, err := d.Registry.documentPlan(modelType)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				return d.unmarshalDocument(data, p, modelValue)
+			}
+		}
+		if err := d.unmarshalStruct(data, modelValue); err != nil {
+			return err
+		}
+		if len(d.scopes) > 0 {
+			if err := d.checkControls(modelValue); err != nil {
+				return err
+			}
+			d.scopes.add(modelValue)
+		}
+		return nil
 	case reflect.Ptr:
-		return m.unmarshalPointer(data, modelValue, modelType)
+		return d.unmarshalPointer(data, modelValue)
 	case reflect.Slice:
-		return m.unmarshalSlice(data, modelValue)
+		return d.unmarshalSlice(data, modelValue)
 	case reflect.Interface:
-		return m.unmarshalInterface(data, modelValue)
+		return d.unmarshalInterface(data, modelValue)
 	}
 
-	return 0, nil
+	return nil
 }
 
-func (m Unmarshaler) unmarshalStruct(data []byte, structValue reflect.Value) (int, error) {
+// unmarshalStruct decodes a single record, following the plan of the struct type
+func (d *decodeState) unmarshalStruct(data []byte, structValue reflect.Value) error {
 	structType := structValue.Type()
 	if structType.Kind() != reflect.Struct {
-		return 0, errors.New("input value is not a struct")
+		return errors.New("input value is not a struct")
+	}
+
+	p, err := planFor(structType)
+	if err != nil {
+		return err
+	}
+	if p.binary {
+		// binary fields are not padded, a missing byte would shift the fields which follow it
+		if _, complete := p.length(data, d.byteMode()); !complete {
+			return d.shortRecord()
+		}
 	}
 
-	index := 0
+	pos, index := 0, 0
 	dataLen := len(data)
-	for i := 0; i < structValue.NumField(); i++ {
+	for _, f := range p.fields {
+		if d.Charset != nil {
+			index = f.offset
+		} else {
+			index = d.WidthMode.upperBound(index, f.offset-pos, data)
+		}
 		if index >= dataLen {
 			break
 		}
 
-		structField := structType.Field(i)
-		fieldValue := structValue.Field(i)
-		limit, ok := m.getLimitFixedTag(structField)
-		if !ok {
-			return 0, fmt.Errorf("invalid fixed tag of field %s", structField.Name)
+		upperBound := d.WidthMode.upperBound(index, f.span, data)
+		if f.usage != usageDisplay || d.Charset != nil {
+			// binary numbers and the characters of a single-byte character set are counted in bytes
+			upperBound = index + f.span
+			if upperBound > dataLen {
+				upperBound = dataLen
+			}
 		}
 
-		if limit == 0 && !isStructOrStructPointer(structField.Type) {
-			continue
+		fieldData := data[index:upperBound]
+		if d.Charset != nil && f.usage == usageDisplay {
+			fieldData = d.Charset.decode(fieldData)
 		}
-
-		var uLen int
-		var dataChunk []byte
-		if limit == 0 && isStructOrStructPointer(structField.Type) {
-			dataChunk = data[index:]
-		} else {
-			upperBound := getUpperBound(index, limit, data)
-			dataChunk = data[index:upperBound]
+		fieldValue, err := fieldByIndexAlloc(structValue, f.index)
+		if err == nil {
+			err = f.decode(d, f, fieldData, fieldValue)
+		}
+		if err != nil {
+			return d.fieldError(f, fieldData, err)
 		}
 
-		uLen, err := m.unmarshal(dataChunk, fieldValue, structField.Type)
+		index, pos = upperBound, f.offset+f.span
+	}
+
+	return nil
+}
+
+// fieldError returns a *FieldError describing the failure of field f
+func (d *decodeState) fieldError(f *fieldPlan, data []byte, err error) error {
+	if _, ok := err.(*FieldError); ok {
+		return err
+	}
+	return &FieldError{
+		Line:   d.line,
+		Offset: f.offset,
+		Field:  f.name,
+		Value:  string(data),
+		Err:    err,
+	}
+}
+
+func decodeBasicType(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	if f.zoned {
+		data = d.removeFieldPadding(f, data)
+		if len(data) == 0 {
+			return nil
+		}
+		digits, err := unpunch(data)
 		if err != nil {
-			return 0, err
+			return err
 		}
+		return d.unmarshalBasicType(digits, f.tagOptions, v)
+	}
+
+	if !f.hasSignOptions() {
+		return d.unmarshalBasicType(d.removeFieldPadding(f, data), f.tagOptions, v)
+	}
 
-		index += uLen
+	sign, digits := splitSign(data, f.tagOptions)
+	digits = d.removeFieldPadding(f, digits)
+	if len(digits) == 0 {
+		return nil
 	}
+	return d.unmarshalBasicType(leadingSign(digits, sign, f.tagOptions), f.tagOptions, v)
+}
 
-	return index, nil
+// decodeInterface stores the field as a string
+func decodeInterface(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	s := reflect.ValueOf(string(d.removeFieldPadding(f, data)))
+	if s.Type().AssignableTo(v.Type()) {
+		v.Set(s)
+	}
+	return nil
 }
 
-func (m Unmarshaler) unmarshalBasicType(data []byte, modelValue reflect.Value) (int, error) {
-	l := len(data)
-	data = removePadding(data)
+// padRune returns the pad character of a field,
+// fieldPad is the pad option of the fixed tag, zero if it is not set.
+func (m Unmarshaler) padRune(fieldPad rune) rune {
+	if fieldPad != 0 {
+		return fieldPad
+	}
+	if m.Pad != 0 {
+		return m.Pad
+	}
+	return rune(spaceByte)
+}
+
+// boolTokens returns the tokens of a bool field,
+// fieldTrue and fieldFalse are the options of the fixed tag, empty if they are not set.
+func (m Unmarshaler) boolTokens(fieldTrue, fieldFalse string) (string, string) {
+	return boolTokens(fieldTrue, fieldFalse, m.TrueValue, m.FalseValue)
+}
+
+// removeFieldPadding removes the padding of field f from data
+func (m Unmarshaler) removeFieldPadding(f *fieldPlan, data []byte) []byte {
+	pad := m.padRune(f.pad)
+	return removePadding(data, f.alignFor(pad), pad)
+}
+
+// unmarshalBasicType parses data, which padding is already removed,
+// opts holds the options of the fixed tag of the field.
+func (d *decodeState) unmarshalBasicType(data []byte, opts tagOptions, modelValue reflect.Value) error {
 	if len(data) == 0 {
-		return l, nil
+		return nil
 	}
 
 	modelType := modelValue.Type()
 
 	switch modelType.Kind() {
+	case reflect.Bool:
+		trueValue, falseValue := d.boolTokens(opts.trueValue, opts.falseValue)
+		switch string(data) {
+		case trueValue:
+			modelValue.SetBool(true)
+		case falseValue:
+			modelValue.SetBool(false)
+		default:
+			return fmt.Errorf("invalid bool value, expected %q or %q", trueValue, falseValue)
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i, err := strconv.ParseInt(string(data), 10, 0)
+		if opts.hasImplied {
+			var err error
+			if data, err = impliedInteger(data, opts.implied); err != nil {
+				return err
+			}
+		}
+		i, err := strconv.ParseInt(string(data), 10, modelType.Bits())
 		if err != nil {
-			return 0, err
+			return err
 		}
 		modelValue.SetInt(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		i, err := strconv.ParseUint(string(data), 10, 0)
+		if opts.hasImplied {
+			var err error
+			if data, err = impliedInteger(data, opts.implied); err != nil {
+				return err
+			}
+		}
+		i, err := strconv.ParseUint(string(data), 10, modelType.Bits())
 		if err != nil {
-			return 0, err
+			return err
 		}
 		modelValue.SetUint(i)
 	case reflect.Float32, reflect.Float64:
+		if opts.hasImplied {
+			data = impliedDecimal(data, opts.implied)
+		}
 		f, err := strconv.ParseFloat(string(data), 64)
 		if err != nil {
-			return 0, err
+			return err
 		}
 		modelValue.SetFloat(f)
 	case reflect.String:
 		modelValue.SetString(string(data))
 	}
 
-	return l, nil
+	return nil
 }
 
-func (m Unmarshaler) unmarshalPointer(data []byte, modelValue reflect.Value, modelType reflect.Type) (int, error) {
+func (d *decodeState) unmarshalPointer(data []byte, modelValue reflect.Value) error {
+	modelType := modelValue.Type()
 	if modelType.Kind() != reflect.Ptr {
-		return 0, errors.New("invalid type")
+		return errors.New("invalid type")
 	}
 
-	newType := modelType.Elem()
-	newValue := reflect.New(newType)
-	l, err := m.unmarshal(data, newValue.Elem(), newType)
+	newValue := reflect.New(modelType.Elem())
+	err := d.unmarshal(data, newValue.Elem())
 	if err != nil {
-		return 0, err
+		return err
 	}
 	modelValue.Set(newValue)
-	return l, nil
+	return nil
 }
 
-func (m Unmarshaler) unmarshalSlice(data []byte, modelValue reflect.Value) (int, error) {
+func (d *decodeState) unmarshalSlice(data []byte, modelValue reflect.Value) error {
 	modelType := modelValue.Type()
-	lines := bytes.Split(data, []byte("\n"))
-	for _, line := range lines {
+	if d.Registry != nil {
+		d.scopes.push()
+		defer d.scopes.pop()
+
+		if elemType := structType(modelType.Elem()); elemType.Kind() == reflect.Struct {
+			p, err := d.Registry.documentPlan(elemType)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				return d.unmarshalDocuments(data, p, modelValue)
+			}
+		}
+	}
+	return d.eachRecord(data, modelType.Elem(), func(record []byte) error {
 		newElem := reflect.New(modelType.Elem()).Elem()
-		_, err := m.unmarshal(line, newElem, modelType.Elem())
+		err := d.unmarshal(record, newElem)
 		if err != nil {
-			return 0, err
+			return err
 		}
 		modelValue.Set(reflect.Append(modelValue, newElem))
+		return nil
+	})
+}
+
+// eachRecord calls fn with every record of data, decoded into values of type t, numbering the lines.
+// With ContinueOnError, the records which cannot be decoded are collected into an ErrorList.
+func (d *decodeState) eachRecord(data []byte, t reflect.Type, fn func(record []byte) error) error {
+	if len(d.trimTerminator(data)) == 0 {
+		// no records, not even an empty one
+		return nil
 	}
 
-	return len(data), nil
+	var errs ErrorList
+	for line, more := 1, true; more; line++ {
+		d.line = line
+		var record []byte
+		var err error
+		record, data, more, err = d.nextRecord(data, t)
+		if err != nil {
+			return err
+		}
+		if d.skipRecord(record) {
+			continue
+		}
+
+		err = fn(record)
+		if err != nil {
+			if !d.ContinueOnError || !isRecordFailure(err) {
+				return err
+			}
+
+			errs = append(errs, err)
+			if d.MaxErrors > 0 && len(errs) >= d.MaxErrors {
+				errs = append(errs, ErrTooManyErrors)
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-func (m Unmarshaler) unmarshalInterface(data []byte, modelValue reflect.Value) (int, error) {
+func (d *decodeState) unmarshalInterface(data []byte, modelValue reflect.Value) error {
+	if d.Registry != nil {
+		return d.unmarshalRecord(data, modelValue)
+	}
+
 	var tempString string
 	newType := reflect.TypeOf(tempString)
+	if !newType.AssignableTo(modelValue.Type()) {
+		return nil
+	}
+
 	newValue := reflect.New(newType)
-	l, err := m.unmarshal(data, newValue.Elem(), newType)
+	err := d.unmarshal(data, newValue.Elem())
 	if err != nil {
-		return 0, err
+		return err
 	}
 	modelValue.Set(newValue.Elem())
-	return l, nil
+	return nil
 }
 
-func removePadding(data []byte) []byte {
-	if len(data) == 0 {
+// removePadding removes the pad characters added by the Marshaler,
+// they are on the right of a left-aligned value, on the left of a right-aligned value
+// and on both sides of a centered value.
+func removePadding(data []byte, align alignment, pad rune) []byte {
+	if align == alignRight || align == alignCenter {
+		for len(data) > 0 {
+			r, s := utf8.DecodeRune(data)
+			if r != pad {
+				break
+			}
+			data = data[s:]
+		}
+	}
+
+	if align == alignRight {
 		return data
 	}
 
-	i := len(data) - 1
-	for ; i >= 0; i-- {
-		if data[i] == spaceByte {
-			continue
+	for len(data) > 0 {
+		r, s := utf8.DecodeLastRune(data)
+		if r != pad {
+			break
 		}
-		break
+		data = data[:len(data)-s]
 	}
-	return data[:i+1]
+	return data
 }
 
 func isBasicType(p reflect.Kind) bool {
 	basicTypes := []reflect.Kind{
+		reflect.Bool,
 		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Float32, reflect.Float64,
@@ -215,21 +496,3 @@ func isStructOrStructPointer(t reflect.Type) bool {
 
 	return false
 }
-
-func getUpperBound(lowerBound, limit int, data []byte) int {
-	diff := 0
-	lenData := len(data)
-	data = data[lowerBound:]
-	for limit > 0 {
-		_, s := utf8.DecodeRune(data)
-		diff += s
-		limit--
-		data = data[s:]
-	}
-	upperBound := lowerBound + diff
-	if upperBound > lenData {
-		upperBound = lenData
-	}
-
-	return upperBound
-}
@@ -317,6 +317,56 @@ func TestUnmarshal(t *testing.T) {
 	})
 }
 
+func TestUnmarshal_Alignment(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want alignedStruct
+	}{
+		{
+			name: "padded",
+			data: "Huy       42  A     1.50",
+			want: alignedStruct{Name: "Huy", Amount: 42, Code: "A", Rate: 1.5},
+		},
+		{
+			name: "uneven center padding",
+			data: "Huy       -7 AB     0.00",
+			want: alignedStruct{Name: "Huy", Amount: -7, Code: "AB", Rate: 0},
+		},
+		{
+			name: "unicode",
+			data: "Đặng       1 Kỹ     1.00",
+			want: alignedStruct{Name: "Đặng", Amount: 1, Code: "Kỹ", Rate: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got alignedStruct
+			err := Unmarshal([]byte(tt.data), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Columns(t *testing.T) {
+	want := columnStruct{Amount: 1234, Name: "Huy", Code: "VN", Note: "abc"}
+
+	var got columnStruct
+	err := Unmarshal([]byte("Huy     #VNabc  1234"), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", got, want)
+	}
+}
+
 func ExampleUnmarshaler_Unmarshal() {
 	var p person
 	m := NewUnmarshaler()
@@ -0,0 +1,385 @@
+package fixedwidth
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// docFieldKind is the kind of a field of a document
+type docFieldKind int
+
+const (
+	// docRecord is a single record of a registered type, optional if the field is a pointer
+	docRecord docFieldKind = iota
+	// docRecords is a slice of consecutive records of a registered type
+	docRecords
+	// docGroup is a struct of records, such as a batch, optional if the field is a pointer
+	docGroup
+	// docGroups is a slice of groups, each of them begins with a record of the begin code
+	docGroups
+)
+
+// docField is a field of a document or a group
+type docField struct {
+	name  string
+	index int
+	kind  docFieldKind
+
+	// code is the code of the record type, or of the record beginning the group
+	code string
+
+	// end is the code of the record ending the group, empty if it is not checked
+	end string
+
+	// group is the plan of a group
+	group *docPlan
+}
+
+// docPlan describes a document, a struct holding records and groups of records
+// of the types of a Registry, in the order of the file
+type docPlan struct {
+	fields []*docField
+}
+
+// docPlanEntry holds the result of building the plan of a document type
+type docPlanEntry struct {
+	plan *docPlan
+	err  error
+}
+
+// documentPlan returns the cached plan of struct type t,
+// the plan is nil if t is not a document of the registry.
+func (r *Registry) documentPlan(t reflect.Type) (*docPlan, error) {
+	if e, ok := r.docs.Load(t); ok {
+		e := e.(*docPlanEntry)
+		return e.plan, e.err
+	}
+
+	p, err := r.buildDocPlan(t, map[reflect.Type]bool{})
+	e, _ := r.docs.LoadOrStore(t, &docPlanEntry{plan: p, err: err})
+	return e.(*docPlanEntry).plan, e.(*docPlanEntry).err
+}
+
+// isDocument reports whether t holds records or groups, rather than being a record itself
+func (r *Registry) isDocument(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	if _, ok := r.codes[t]; ok {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if _, ok := r.recordCode(field.Type); ok {
+			return true
+		}
+		if _, ok := groupTag(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCode returns the code of the record type of a field, a registered struct, a pointer to it or a slice of them
+func (r *Registry) recordCode(t reflect.Type) (string, bool) {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	code, ok := r.codes[t]
+	return code, ok
+}
+
+// groupTag returns the begin and end codes of a group field, tagged `fixed:"begin=5,end=8"`
+func groupTag(field reflect.StructField) ([2]string, bool) {
+	var codes [2]string
+	t, ok := field.Tag.Lookup(tagName)
+	if !ok || !strings.HasPrefix(strings.TrimSpace(t), "begin=") && !strings.HasPrefix(strings.TrimSpace(t), "end=") {
+		return codes, false
+	}
+
+	for _, option := range strings.Split(t, ",") {
+		i := strings.IndexByte(option, '=')
+		if i < 0 {
+			continue
+		}
+		switch strings.TrimSpace(option[:i]) {
+		case "begin":
+			codes[0] = option[i+1:]
+		case "end":
+			codes[1] = option[i+1:]
+		}
+	}
+	return codes, true
+}
+
+func (r *Registry) buildDocPlan(t reflect.Type, parents map[reflect.Type]bool) (*docPlan, error) {
+	if !r.isDocument(t) {
+		return nil, nil
+	}
+	if parents[t] {
+		return nil, fmt.Errorf("recursive group type %s", t)
+	}
+	parents[t] = true
+	defer delete(parents, t)
+
+	p := &docPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		f := &docField{name: field.Name, index: i}
+		if code, ok := r.recordCode(field.Type); ok {
+			f.kind, f.code = docRecord, code
+			if field.Type.Kind() == reflect.Slice {
+				f.kind = docRecords
+			}
+			p.fields = append(p.fields, f)
+			continue
+		}
+
+		codes, ok := groupTag(field)
+		if !ok {
+			return nil, fmt.Errorf("field %s of %s is neither a record nor a group", field.Name, t)
+		}
+		if codes[0] == "" {
+			return nil, fmt.Errorf("group %s of %s has no begin code", field.Name, t)
+		}
+		for _, code := range codes {
+			if _, ok := r.types[code]; code != "" && !ok {
+				return nil, fmt.Errorf("group %s of %s: code %q is not registered", field.Name, t, code)
+			}
+		}
+
+		groupType := field.Type
+		f.kind = docGroup
+		if groupType.Kind() == reflect.Slice {
+			groupType, f.kind = groupType.Elem(), docGroups
+		}
+		if groupType.Kind() == reflect.Ptr {
+			groupType = groupType.Elem()
+		}
+		group, err := r.buildDocPlan(groupType, parents)
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			return nil, fmt.Errorf("group %s of %s holds no record", field.Name, t)
+		}
+		if first := group.fields[0]; first.kind != docRecord || first.code != codes[0] {
+			return nil, fmt.Errorf("group %s of %s does not begin with a record of code %q", field.Name, t, codes[0])
+		}
+		f.code, f.end, f.group = codes[0], codes[1], group
+		p.fields = append(p.fields, f)
+	}
+	return p, nil
+}
+
+// docLine is a record of a document with its line number
+type docLine struct {
+	line int
+	code string
+	data []byte
+}
+
+// docReader reads the records of a document in order
+type docReader struct {
+	lines []docLine
+	next  int
+}
+
+// unexpectedRecord returns the error of the next record, which has no place in the document
+func (r *docReader) unexpectedRecord() error {
+	l := r.lines[r.next]
+	return &RecordError{Line: l.line, Err: fmt.Errorf("unexpected record type %q", l.code)}
+}
+
+// peek returns the code of the next record, empty at the end of the document
+func (r *docReader) peek() string {
+	if r.next >= len(r.lines) {
+		return ""
+	}
+	return r.lines[r.next].code
+}
+
+// unmarshalDocument decodes the records of data into the document v
+func (d *decodeState) unmarshalDocument(data []byte, p *docPlan, v reflect.Value) error {
+	r, err := d.readDocument(data)
+	if err != nil {
+		return err
+	}
+	if err := d.unmarshalGroup(r, p, v); err != nil {
+		return err
+	}
+	if r.next < len(r.lines) {
+		return r.unexpectedRecord()
+	}
+	return nil
+}
+
+// unmarshalDocuments decodes the records of data into consecutive documents appended to the slice v
+func (d *decodeState) unmarshalDocuments(data []byte, p *docPlan, v reflect.Value) error {
+	if len(d.trimTerminator(data)) == 0 {
+		return nil
+	}
+	r, err := d.readDocument(data)
+	if err != nil {
+		return err
+	}
+
+	for r.next < len(r.lines) {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		doc := elem
+		if doc.Kind() == reflect.Ptr {
+			doc.Set(reflect.New(doc.Type().Elem()))
+			doc = doc.Elem()
+		}
+		next := r.next
+		if err := d.unmarshalGroup(r, p, doc); err != nil {
+			return err
+		}
+		if r.next == next {
+			// the record does not begin a document
+			return r.unexpectedRecord()
+		}
+		v.Set(reflect.Append(v, elem))
+	}
+	return nil
+}
+
+// readDocument splits data into the records of a document
+func (d *decodeState) readDocument(data []byte) (*docReader, error) {
+	r := &docReader{}
+	for line, more := 1, true; more; line++ {
+		d.line = line
+		var record []byte
+		var err error
+		record, data, more, err = d.nextRecord(data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if d.skipRecord(record) {
+			continue
+		}
+		r.lines = append(r.lines, docLine{line: line, code: string(d.recordCode(record)), data: record})
+	}
+	return r, nil
+}
+
+// unmarshalGroup decodes the fields of a document or group from the records of r
+func (d *decodeState) unmarshalGroup(r *docReader, p *docPlan, v reflect.Value) error {
+	d.scopes.push()
+	defer d.scopes.pop()
+
+	for _, f := range p.fields {
+		fv := v.Field(f.index)
+		switch f.kind {
+		case docRecord, docGroup:
+			if r.peek() != f.code {
+				if fv.Kind() == reflect.Ptr {
+					continue
+				}
+				return d.missingRecord(r, f)
+			}
+			if err := d.unmarshalDocField(r, f, fv); err != nil {
+				return err
+			}
+		case docRecords, docGroups:
+			for r.peek() == f.code {
+				elem := reflect.New(fv.Type().Elem()).Elem()
+				if err := d.unmarshalDocField(r, f, elem); err != nil {
+					return err
+				}
+				fv.Set(reflect.Append(fv, elem))
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalDocField decodes the next record, or group, of r into v
+func (d *decodeState) unmarshalDocField(r *docReader, f *docField, v reflect.Value) error {
+	if f.kind == docRecord || f.kind == docRecords {
+		l := r.lines[r.next]
+		r.next++
+		d.line = l.line
+		return d.unmarshal(l.data, v)
+	}
+
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if err := d.unmarshalGroup(r, f.group, v); err != nil {
+		return err
+	}
+	if f.end != "" && r.lines[r.next-1].code != f.end {
+		// the group is not closed by its end record
+		return d.missingRecord(r, &docField{name: f.name, code: f.end})
+	}
+	return nil
+}
+
+// missingRecord returns the error of a record of code f.code expected at the current position of r
+func (d *decodeState) missingRecord(r *docReader, f *docField) error {
+	if r.next >= len(r.lines) {
+		line := 1
+		if len(r.lines) > 0 {
+			line = r.lines[len(r.lines)-1].line
+		}
+		return &RecordError{Line: line, Err: fmt.Errorf("%s: missing record type %q at the end of the data", f.name, f.code)}
+	}
+
+	l := r.lines[r.next]
+	return &RecordError{Line: l.line, Err: fmt.Errorf("%s: expected record type %q, got %q", f.name, f.code, l.code)}
+}
+
+// marshalDocument appends the records of the document v in order, separated by the Terminator.
+// first is the line of the first record of the document.
+func (m *Marshaler) marshalDocument(p *docPlan, v reflect.Value, first int) error {
+	m.scopes.push()
+	defer m.scopes.pop()
+
+	for _, f := range p.fields {
+		fv := v.Field(f.index)
+		if f.kind == docRecord || f.kind == docGroup {
+			if err := m.marshalDocField(f, fv, first); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := 0; i < fv.Len(); i++ {
+			if err := m.marshalDocField(f, fv.Index(i), first); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// marshalDocField appends the record, or the records of the group, v
+func (m *Marshaler) marshalDocField(f *docField, v reflect.Value, first int) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if f.kind == docGroup || f.kind == docGroups {
+		return m.marshalDocument(f.group, v, first)
+	}
+
+	if m.line >= first {
+		m.b = append(m.b, m.Terminator...)
+	}
+	return m.marshal(v)
+}
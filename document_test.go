@@ -0,0 +1,219 @@
+package fixedwidth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type docFileHeader struct {
+	Code string `fixed:"1"`
+	Name string `fixed:"6"`
+}
+
+type docBatchHeader struct {
+	Code  string `fixed:"1"`
+	Batch int    `fixed:"3,right,pad=0"`
+}
+
+type docEntry struct {
+	Code    string `fixed:"1"`
+	Routing string `fixed:"4"`
+	Amount  int    `fixed:"5,right,pad=0"`
+}
+
+type docBatchControl struct {
+	Code   string `fixed:"1"`
+	Count  int    `fixed:"3,right,pad=0,count=6"`
+	Hash   int    `fixed:"2,right,pad=0,hash=Routing"`
+	Amount int    `fixed:"6,right,pad=0,sum=Amount"`
+}
+
+type docFileControl struct {
+	Code    string `fixed:"1"`
+	Batches int    `fixed:"3,right,pad=0,count=5"`
+	Records int    `fixed:"3,right,pad=0,count"`
+	Amount  int64  `fixed:"7,right,pad=0,sum=Amount"`
+}
+
+type docBatch struct {
+	Header  docBatchHeader
+	Entries []docEntry
+	Control docBatchControl
+}
+
+type docFile struct {
+	Header  docFileHeader
+	Batches []docBatch `fixed:"begin=5,end=8"`
+	Trailer *docFileControl
+}
+
+var docTypes = map[string]interface{}{
+	"1": docFileHeader{},
+	"5": docBatchHeader{},
+	"6": docEntry{},
+	"8": docBatchControl{},
+	"9": docFileControl{},
+}
+
+var docValue = docFile{
+	Header: docFileHeader{Code: "1", Name: "ACME"},
+	Batches: []docBatch{
+		{
+			Header:  docBatchHeader{Code: "5", Batch: 1},
+			Entries: []docEntry{{Code: "6", Routing: "0012", Amount: 150}, {Code: "6", Routing: "0099", Amount: 25}},
+			Control: docBatchControl{Code: "8", Count: 2, Hash: 11, Amount: 175},
+		},
+		{
+			Header:  docBatchHeader{Code: "5", Batch: 2},
+			Control: docBatchControl{Code: "8"},
+		},
+	},
+	Trailer: &docFileControl{Code: "9", Batches: 2, Records: 7, Amount: 175},
+}
+
+const docData = "1ACME  \n" + "5001\n" + "6001200150\n" + "6009900025\n" + "800211000175\n" +
+	"5002\n" + "800000000000\n" + "90020070000175"
+
+func TestDocument(t *testing.T) {
+	r := newRegistry(t, 1, 1, docTypes)
+
+	m := NewMarshaler()
+	m.Registry = r
+	got, err := m.Marshal(docValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != docData {
+		t.Errorf("Marshal() got = %q, want %q", got, docData)
+	}
+
+	u := NewUnmarshaler()
+	u.Registry = r
+	var file docFile
+	if err := u.Unmarshal([]byte(docData), &file); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file, docValue) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", file, docValue)
+	}
+
+	// the trailer is optional
+	var short docFile
+	if err := u.Unmarshal([]byte("1ACME  \n5001\n800000000000"), &short); err != nil {
+		t.Fatal(err)
+	}
+	if short.Trailer != nil || len(short.Batches) != 1 {
+		t.Errorf("Unmarshal() got = %+v, want one batch and no trailer", short)
+	}
+}
+
+func TestDocument_Slice(t *testing.T) {
+	r := newRegistry(t, 1, 1, docTypes)
+
+	m := NewMarshaler()
+	m.Registry = r
+	files := []docFile{docValue, docValue}
+	data, err := m.Marshal(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := docData + "\n" + docData; string(data) != want {
+		t.Errorf("Marshal() got = %q, want %q", data, want)
+	}
+
+	u := NewUnmarshaler()
+	u.Registry = r
+	var got []docFile
+	if err := u.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", got, files)
+	}
+
+	var pointers []*docFile
+	if err := u.Unmarshal(data, &pointers); err != nil {
+		t.Fatal(err)
+	}
+	if len(pointers) != 2 || !reflect.DeepEqual(*pointers[1], docValue) {
+		t.Errorf("Unmarshal() got = %+v, want 2 documents", pointers)
+	}
+
+	var empty []docFile
+	if err := u.Unmarshal(nil, &empty); err != nil || len(empty) != 0 {
+		t.Errorf("Unmarshal() got = %+v, %v, want no documents", empty, err)
+	}
+
+	// a document cannot begin with a batch
+	var wrong []docFile
+	err = u.Unmarshal([]byte(docData+"\n5003\n800000000000"), &wrong)
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) || recordErr.Line != 9 {
+		t.Errorf("Unmarshal() got error %v, want a RecordError on line 9", err)
+	}
+}
+
+func TestDocument_Errors(t *testing.T) {
+	r := newRegistry(t, 1, 1, docTypes)
+	u := NewUnmarshaler()
+	u.Registry = r
+
+	tests := []struct {
+		name string
+		data string
+		line int
+	}{
+		{name: "missing header", data: "5001\n800000000000", line: 1},
+		{name: "missing batch control", data: "1ACME  \n5001\n6001200150\n90010030000150", line: 4},
+		{name: "missing batch control at the end", data: "1ACME  \n5001\n6001200150", line: 3},
+		{name: "unexpected record", data: "1ACME  \n5001\n800000000000\n90010030000000\n6001200150", line: 5},
+		{name: "unknown record", data: "1ACME  \n7001", line: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file docFile
+			err := u.Unmarshal([]byte(tt.data), &file)
+			var recordErr *RecordError
+			if !errors.As(err, &recordErr) || recordErr.Line != tt.line {
+				t.Errorf("Unmarshal() got error %v, want a RecordError on line %d", err, tt.line)
+			}
+		})
+	}
+
+	var field docFile
+	err := u.Unmarshal([]byte("1ACME  \n5x01\n800000000000"), &field)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Line != 2 {
+		t.Errorf("Unmarshal() got error %v, want a FieldError on line 2", err)
+	}
+
+	plans := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "unregistered begin code", v: &struct {
+			Header  docFileHeader
+			Batches []docBatch `fixed:"begin=7"`
+		}{}},
+		{name: "wrong begin code", v: &struct {
+			Header  docFileHeader
+			Batches []docBatch `fixed:"begin=6"`
+		}{}},
+		{name: "no begin code", v: &struct {
+			Header  docFileHeader
+			Batches []docBatch `fixed:"end=8"`
+		}{}},
+		{name: "neither record nor group", v: &struct {
+			Header docFileHeader
+			Name   string
+		}{}},
+	}
+	for _, tt := range plans {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := u.Unmarshal([]byte("1ACME  "), tt.v); err == nil {
+				t.Error("Unmarshal() expected error")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -17,13 +18,70 @@ type Marshaler struct {
 	// After each marshal, b is reused via reset method.
 	// By reusing b, we can minimize number of allocations
 	b []byte
-	tag
+
+	// line is the number of the record being encoded, starting from 1
+	line int
+
+	// binary holds the bounds of the binary fields of the record in b, they are not transcoded
+	binary [][2]int
+
+	// scopes holds the records encoded in every enclosing group, they are controlled by trailer records
+	scopes controlScopes
+
+	// Pad is the character filling fields which are shorter than their width,
+	// the pad option of the fixed tag overrides it for a single field.
+	// The zero value means a space.
+	Pad rune
+
+	// TimeLayout is the layout of time.Time fields, see time.Time.Format.
+	// The time option of the fixed tag overrides it for a single field.
+	// The zero value means time.RFC3339.
+	TimeLayout string
+
+	// TimeLocation, if it is set, is the location time.Time fields are converted to before formatting.
+	TimeLocation *time.Location
+
+	// TrueValue and FalseValue are the tokens of bool fields,
+	// the true and false options of the fixed tag override them for a single field.
+	// The zero values mean "1" and "0".
+	TrueValue, FalseValue string
+
+	// Charset is the single-byte character set of the text fields,
+	// the values are converted to it after they are padded or truncated.
+	// The zero value means UTF-8.
+	Charset *Charset
+
+	// WidthMode is the unit of the width of fields.
+	// It is ignored when Charset is set, every character is then a byte.
+	WidthMode WidthMode
+
+	// Overflow is the handling of values longer than their field,
+	// the overflow option of the fixed tag overrides it for a single field.
+	// The zero value truncates text and fails on numbers.
+	Overflow Overflow
+
+	// OverflowMarker fills the fields which overflow with OverflowMark.
+	// The zero value means '*'.
+	OverflowMarker rune
+
+	// Registry, if it is set, holds the record types of the data.
+	// Every record is then of a registered type and its code is written in the discriminator columns.
+	Registry *Registry
+
+	// Terminator separates the records, e.g. "\r\n". It is written as is, without conversion to the Charset.
+	// NewMarshaler sets it to a new line character (\n).
+	// If it is empty, records follow each other, each of them taking the width of its struct.
+	Terminator []byte
+
+	// TrailingTerminator makes Marshal write the Terminator after the last record as well.
+	// An Encoder always writes it after every record.
+	TrailingTerminator bool
 }
 
 // NewMarshaler create new Marshaler
 // When creating new Marshaler, you should consider b field
 func NewMarshaler() *Marshaler {
-	return &Marshaler{}
+	return &Marshaler{Terminator: []byte("\n")}
 }
 
 // Marshal returns the fixed-width encoding of v.
@@ -33,13 +91,17 @@ func NewMarshaler() *Marshaler {
 // Each field in a struct need to be defined a `fixed` tag.
 // The `fixed` tag indicates the maximum width of current field.
 //
-// If v is slice of struct, Marshal will return multi lines separated by new line character (\n).
+// If v is slice of struct, Marshal will return multi records separated by the Terminator.
 func (m *Marshaler) Marshal(v interface{}) ([]byte, error) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
 	m.reset()
+	m.line = 0
 	err := m.marshal(reflect.ValueOf(v))
+	if err == nil && m.TrailingTerminator && len(m.b) > 0 {
+		m.b = append(m.b, m.Terminator...)
+	}
 	return m.b, err
 }
 
@@ -51,6 +113,11 @@ func (m *Marshaler) reset() {
 func (m *Marshaler) marshal(v reflect.Value) error {
 	vKind := v.Kind()
 	if vKind == reflect.Slice {
+		if m.Registry != nil {
+			m.scopes.push()
+			defer m.scopes.pop()
+		}
+
 		vLen := v.Len()
 		for i := 0; i < vLen; i++ {
 			err := m.marshal(v.Index(i))
@@ -59,61 +126,237 @@ func (m *Marshaler) marshal(v reflect.Value) error {
 			}
 
 			if i != vLen-1 {
-				m.b = append(m.b, '\n')
+				m.b = append(m.b, m.Terminator...)
 			}
 		}
 		return nil
 	}
 
-	if vKind == reflect.Ptr || vKind == reflect.Interface {
+	for (vKind == reflect.Ptr || vKind == reflect.Interface) && !v.IsNil() {
 		v = v.Elem()
+		vKind = v.Kind()
 	}
 
 	if vKind != reflect.Struct {
 		return nil
 	}
 
-	vType := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		fv := v.Field(i)
-		structField := vType.Field(i)
-		limit, ok := m.getLimitFixedTag(structField)
-		if !ok {
-			return fmt.Errorf("invalid fixed tag of field %s", structField.Name)
+	if m.Registry != nil {
+		p, err := m.Registry.documentPlan(v.Type())
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			return m.marshalDocument(p, v, m.line+1)
 		}
+	}
 
-		if fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
-			fv = fv.Elem()
+	if len(m.scopes) > 0 {
+		var err error
+		if v, err = m.fillControls(v); err != nil {
+			return err
 		}
+	}
+
+	start := len(m.b)
+	m.line++
+	m.binary = m.binary[:0]
+	err := m.marshalStruct(v)
+	if err == nil && m.Registry != nil {
+		err = m.writeCode(v.Type(), start)
+	}
+	if err != nil {
+		return err
+	}
+	m.scopes.add(v)
+	if m.Charset != nil {
+		if err := m.transcode(start); err != nil {
+			return err
+		}
+	}
+	if len(m.binary) > 0 && m.splitsRecord(start) {
+		return &RecordError{Line: m.line, Err: fmt.Errorf("a binary field contains the terminator %q, records with binary fields need an empty Terminator", m.Terminator)}
+	}
+	return nil
+}
+
+// transcode converts the record starting at start from UTF-8 to the character set of m,
+// except the binary fields.
+func (m *Marshaler) transcode(start int) error {
+	w, r := start, start
+	for _, bounds := range append(m.binary, [2]int{len(m.b), len(m.b)}) {
+		n, err := m.Charset.encode(m.b[w:], m.b[r:bounds[0]])
+		if err != nil {
+			return fmt.Errorf("column %d: %w", w-start+n+1, err)
+		}
+		w += n
+		w += copy(m.b[w:], m.b[bounds[0]:bounds[1]])
+		r = bounds[1]
+	}
+	m.b = m.b[:w]
+	return nil
+}
+
+// marshalStruct appends a struct as a single record, following the plan of its type
+func (m *Marshaler) marshalStruct(v reflect.Value) error {
+	p, err := planFor(v.Type())
+	if err != nil {
+		return err
+	}
+	if n := len(m.b) + p.width; n > cap(m.b) {
+		// grow b once for the whole record, ASCII text takes a byte per column
+		b := make([]byte, len(m.b), 2*cap(m.b)+p.width)
+		m.b = b[:copy(b, m.b)]
+	}
+
+	pos := 0
+	for _, f := range p.fields {
+		m.appendPadding(f.offset-pos, m.padRune(0))
 
 		startOffset := len(m.b)
-		if fv.Kind() == reflect.Struct {
-			err := m.marshal(fv)
+		fv := fieldByIndex(v, f.index)
+		if fv.IsValid() {
+			err := f.encode(m, f, fv)
+			if err != nil {
+				if _, ok := err.(*FieldError); ok {
+					return err
+				}
+				return fmt.Errorf("field %s: %w", f.name, err)
+			}
+		}
+
+		if f.usage != usageDisplay {
+			// binary numbers have the exact width, in bytes
+			if len(m.b) == startOffset {
+				m.b = append(m.b, make([]byte, f.width)...)
+			}
+			m.b = m.b[:startOffset+f.span]
+			m.binary = append(m.binary, [2]int{startOffset, len(m.b)})
+			pos = f.offset + f.span
+			continue
+		}
+
+		pad := m.padRune(f.pad)
+		align := f.alignFor(pad)
+		lowerBound, limit := startOffset, f.width
+		var trailingSign byte
+		switch {
+		case f.sign == signTrailingSeparate && len(m.b) > startOffset:
+			// the sign is kept in the last column
+			trailingSign = m.b[len(m.b)-1]
+			m.b, limit = m.b[:len(m.b)-1], limit-1
+		case f.numeric && hasSign(m.b[startOffset:]) && (f.sign == signLeadingSeparate || pad == '0' && align == alignRight):
+			// the pad characters are filled between the sign and the digits
+			lowerBound, limit = lowerBound+1, limit-1
+		}
+
+		// the value is scanned once, unless it overflows
+		n, width := m.widthMode().prefix(m.b[lowerBound:], limit)
+		filled := false
+		if lowerBound+n < len(m.b) {
+			filled, err = m.overflow(f, startOffset, lowerBound, limit)
 			if err != nil {
 				return err
 			}
-		} else {
-			m.appendExtractedScalarValue(fv)
+			n, width = m.widthMode().prefix(m.b[lowerBound:], limit)
+		}
+		if !filled {
+			m.addPadding(limit, lowerBound, n, width, align, pad)
+			if trailingSign != 0 {
+				m.b = append(m.b, trailingSign)
+			}
 		}
+		if f.span < f.width {
+			m.truncateOrAddPadding(f.span, startOffset, alignLeft, pad)
+		}
+		pos = f.offset + f.span
+	}
+	m.appendPadding(p.width-pos, m.padRune(0))
+
+	return nil
+}
+
+// padRune returns the pad character of a field,
+// fieldPad is the pad option of the fixed tag, zero if it is not set.
+func (m *Marshaler) padRune(fieldPad rune) rune {
+	if fieldPad != 0 {
+		return fieldPad
+	}
+	if m.Pad != 0 {
+		return m.Pad
+	}
+	return rune(spaceByte)
+}
+
+func hasSign(b []byte) bool {
+	return len(b) > 0 && (b[0] == '-' || b[0] == '+')
+}
+
+// boolTokens returns the tokens of a bool field,
+// fieldTrue and fieldFalse are the options of the fixed tag, empty if they are not set.
+func (m *Marshaler) boolTokens(fieldTrue, fieldFalse string) (string, string) {
+	return boolTokens(fieldTrue, fieldFalse, m.TrueValue, m.FalseValue)
+}
 
-		if limit > 0 {
-			m.truncateOrAddPadding(limit, startOffset)
+func encodeBasicType(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	start := len(m.b)
+	m.appendExtractedScalarValue(f, v)
+	if !f.zoned {
+		m.b = placeSign(m.b, start, f.tagOptions)
+		return nil
+	}
+
+	var err error
+	m.b, err = overpunch(m.b, start)
+	return err
+}
+
+// encodeInterface encodes the dynamic value of an interface field with the same precedence as codecFor,
+// a struct is encoded as a nested record then limited by the width of the field.
+func encodeInterface(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
 		}
+		v = v.Elem()
 	}
 
+	switch {
+	case implements(v.Type(), marshalerType):
+		return encodeMarshaler(m, f, v)
+	case v.Type() == timeType:
+		return encodeTime(m, f, v)
+	case isBasicType(v.Kind()):
+		m.appendExtractedScalarValue(f, v)
+	case implements(v.Type(), textMarshalerType):
+		return encodeTextMarshaler(m, f, v)
+	case v.Kind() == reflect.Struct:
+		return m.marshalStruct(v)
+	}
 	return nil
 }
 
-func (m *Marshaler) appendExtractedScalarValue(v reflect.Value) {
+func (m *Marshaler) appendExtractedScalarValue(f *fieldPlan, v reflect.Value) {
 	switch v.Kind() {
+	case reflect.Bool:
+		trueValue, falseValue := m.boolTokens(f.trueValue, f.falseValue)
+		if v.Bool() {
+			m.b = append(m.b, trueValue...)
+		} else {
+			m.b = append(m.b, falseValue...)
+		}
 	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
 		m.b = strconv.AppendInt(m.b, v.Int(), 10)
 	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
 		m.b = strconv.AppendUint(m.b, v.Uint(), 10)
-	case reflect.Float32:
-		m.b = strconv.AppendFloat(m.b, v.Float(), 'f', 2, 32)
-	case reflect.Float64:
-		m.b = strconv.AppendFloat(m.b, v.Float(), 'f', 2, 64)
+	case reflect.Float32, reflect.Float64:
+		bitSize := v.Type().Bits()
+		if f.hasImplied {
+			m.b = appendImpliedFloat(m.b, v.Float(), f.implied, bitSize)
+			break
+		}
+		format, prec := f.floatFormat()
+		m.b = strconv.AppendFloat(m.b, v.Float(), format, prec, bitSize)
 	case reflect.String:
 		m.b = append(m.b, v.String()...)
 	}
@@ -121,37 +364,91 @@ func (m *Marshaler) appendExtractedScalarValue(v reflect.Value) {
 	return
 }
 
-func (m *Marshaler) truncateOrAddPadding(limit, lowerBound int) {
+// widthMode returns the unit of the width of fields
+func (m *Marshaler) widthMode() WidthMode {
+	if m.Charset != nil {
+		return Runes
+	}
+	return m.WidthMode
+}
+
+// appendPadding appends pad characters filling the given width,
+// spaces fill the remaining width if the pad character is wider than it.
+func (m *Marshaler) appendPadding(width int, pad rune) {
+	padWidth := m.widthMode().runeWidth(pad, utf8.RuneLen(pad))
+	if padWidth <= 0 {
+		pad, padWidth = rune(spaceByte), 1
+	}
+
+	for ; width >= padWidth; width -= padWidth {
+		if pad < utf8.RuneSelf {
+			m.b = append(m.b, byte(pad))
+			continue
+		}
+		m.b = appendRune(m.b, pad)
+	}
+	for ; width > 0; width-- {
+		m.b = append(m.b, spaceByte)
+	}
+}
+
+// rotate moves the last n bytes of b to its beginning
+func rotate(b []byte, n int) {
+	reverse(b[:len(b)-n])
+	reverse(b[len(b)-n:])
+	reverse(b)
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+// truncateOrAddPadding limits the bytes from lowerBound to the given width, see WidthMode.
+// Redundant characters on the right are truncated, without splitting a character,
+// then pad characters are added around the value depending on the alignment.
+func (m *Marshaler) truncateOrAddPadding(limit, lowerBound int, align alignment, pad rune) {
+	n, width := m.widthMode().prefix(m.b[lowerBound:], limit)
+	m.addPadding(limit, lowerBound, n, width, align, pad)
+}
+
+// addPadding keeps the first n bytes from lowerBound, their width is returned by WidthMode.prefix,
+// then pads them to limit depending on the alignment.
+func (m *Marshaler) addPadding(limit, lowerBound, n, width int, align alignment, pad rune) {
 	if limit == 0 {
 		return
 	}
 
-	b := m.b[lowerBound:]
-	totalRunes := utf8.RuneCount(b)
-	padding := limit - totalRunes
+	// exclude redundant bytes
+	m.b = m.b[:lowerBound+n]
+	padding := limit - width
 	if padding == 0 {
 		return
 	}
 
-	if padding < 0 {
-		// exclude redundant bytes
-		m.b = m.b[:lowerBound+getFirstInvalidRune(limit, b)-1]
-		return
+	left := 0
+	switch align {
+	case alignRight:
+		left = padding
+	case alignCenter:
+		left = padding / 2
 	}
 
-	// append additional spaces
-	for i := 0; i < padding; i++ {
-		m.b = append(m.b, spaceByte)
+	if left > 0 {
+		// append the padding before the value then move it to the front
+		end := len(m.b)
+		m.appendPadding(left, pad)
+		rotate(m.b[lowerBound:], len(m.b)-end)
 	}
-	return
-}
 
-func getFirstInvalidRune(noRunes int, b []byte) int {
-	i := 1
-	for noRunes > 0 {
-		_, s := utf8.DecodeRune(b)
-		i += s
-		noRunes--
-	}
-	return i
+	// append additional pad characters
+	m.appendPadding(padding-left, pad)
+	return
 }
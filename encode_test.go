@@ -1,6 +1,7 @@
 package fixedwidth
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -225,3 +226,141 @@ func ExampleMarshaler_Marshal() {
 	// Output:
 	// Alexander Goodword  40  Software
 }
+
+func TestMarshal_Alignment(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       alignedStruct
+		want    string
+		wantErr error
+	}{
+		{
+			name: "padded",
+			v:    alignedStruct{Name: "Huy", Amount: 42, Code: "A", Rate: 1.5},
+			want: "Huy       42  A     1.50",
+		},
+		{
+			name: "uneven center padding",
+			v:    alignedStruct{Name: "Huy", Amount: -7, Code: "AB", Rate: 0},
+			want: "Huy       -7 AB     0.00",
+		},
+		{
+			name:    "truncated",
+			v:       alignedStruct{Name: "Alexander", Amount: 1234567, Code: "ABCDEF", Rate: 12345.678},
+			wantErr: ErrOverflow,
+		},
+		{
+			name: "truncated text",
+			v:    alignedStruct{Name: "Alexander", Amount: 123456, Code: "ABCDEF", Rate: 12345.678},
+			want: "Alexan123456ABCDE12345.7",
+		},
+		{
+			name: "unicode",
+			v:    alignedStruct{Name: "Đặng", Amount: 1, Code: "Kỹ", Rate: 1},
+			want: "Đặng       1 Kỹ     1.00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if tt.wantErr != nil {
+				// numbers which do not fit fail instead of being truncated
+				var fieldErr *FieldError
+				if !errors.As(err, &fieldErr) || !errors.Is(err, tt.wantErr) || fieldErr.Field != "Amount" {
+					t.Errorf("Marshal() got error %v, want a FieldError of Amount wrapping %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_Padding(t *testing.T) {
+	tests := []struct {
+		name string
+		pad  rune
+		v    paddedStruct
+		want string
+	}{
+		{
+			name: "field pad",
+			v:    paddedStruct{Name: "Huy", Amount: 42, Code: "A", Rate: 1.5, Note: "x"},
+			want: "Huy   000042A****0001.50···x",
+		},
+		{
+			name: "negative numbers filled with zeros",
+			v:    paddedStruct{Name: "Huy", Amount: -42, Code: "A", Rate: -1.5, Note: "x"},
+			want: "Huy   -00042A****-001.50···x",
+		},
+		{
+			name: "marshaler pad",
+			pad:  '_',
+			v:    paddedStruct{Name: "Huy", Amount: 42, Code: "A", Rate: 1.5, Note: "xy"},
+			want: "Huy___000042A****0001.50··xy",
+		},
+		{
+			name: "multi-byte marshaler pad",
+			pad:  '•',
+			v:    paddedStruct{Name: "Huy", Amount: 42, Code: "ABCDEF", Rate: 1.5, Note: "xy"},
+			want: "Huy•••000042ABCDE0001.50··xy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.Pad = tt.pad
+			got, err := m.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.want)
+			}
+
+			u := NewUnmarshaler()
+			u.Pad = tt.pad
+			var back paddedStruct
+			if err := u.Unmarshal(got, &back); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.v
+			if len(want.Code) > 5 {
+				want.Code = want.Code[:5]
+			}
+			if !reflect.DeepEqual(back, want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, want)
+			}
+		})
+	}
+}
+
+func TestMarshal_Columns(t *testing.T) {
+	v := columnStruct{Amount: 1234, Name: "Huy", Code: "VN", Note: "abcd"}
+	want := "Huy      VNabc  1234"
+
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+
+	m := NewMarshaler()
+	m.Pad = '.'
+	got, err = m.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Huy......VNabc..1234"; string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+}
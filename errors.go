@@ -0,0 +1,124 @@
+package fixedwidth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooManyErrors ends an ErrorList when decoding stopped after Unmarshaler.MaxErrors failed records.
+var ErrTooManyErrors = errors.New("too many errors")
+
+// FieldError describes a field of a record which cannot be decoded, or encoded.
+type FieldError struct {
+	// Line is the number of the record, starting from 1
+	Line int
+
+	// Offset is the position of the first rune of the field in the record, starting from 0
+	Offset int
+
+	// Field is the path of the struct field, e.g. Order.Customer.Zip
+	Field string
+
+	// Value is the raw text of the field
+	Value string
+
+	// Err is the underlying error
+	Err error
+
+	// encode is true if the field cannot be encoded rather than decoded
+	encode bool
+}
+
+func (e *FieldError) Error() string {
+	op := "parse"
+	if e.encode {
+		op = "encode"
+	}
+	return fmt.Sprintf("line %d, offset %d, field %s: cannot %s %q: %v", e.Line, e.Offset, e.Field, op, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// RecordError describes a record which cannot be decoded, or encoded, as a whole.
+type RecordError struct {
+	// Line is the number of the record, starting from 1
+	Line int
+
+	// Err is the underlying error
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// ControlTotalError describes a control field of a trailer record, such as a record count
+// or a sum, which does not match the records it controls.
+type ControlTotalError struct {
+	// Line is the number of the trailer record, starting from 1
+	Line int
+
+	// Field is the path of the control field
+	Field string
+
+	// Expected is the total computed from the records, Actual is the value of the field
+	Expected, Actual int64
+}
+
+func (e *ControlTotalError) Error() string {
+	return fmt.Sprintf("line %d, field %s: control total is %d, want %d", e.Line, e.Field, e.Actual, e.Expected)
+}
+
+// ErrorList holds the errors of the records which cannot be decoded, in order of lines.
+// It is returned by an Unmarshaler with ContinueOnError set.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	if len(l) == 1 {
+		return l[0].Error()
+	}
+
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(l), strings.Join(msgs, "; "))
+}
+
+// Is reports whether an error of the list matches target, see errors.Is
+func (l ErrorList) Is(target error) bool {
+	for _, err := range l {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the list which matches target and sets target to it, see errors.As
+func (l ErrorList) As(target interface{}) bool {
+	for _, err := range l {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRecordFailure reports whether err is caused by the data of a record,
+// rather than by the definition of the struct.
+func isRecordFailure(err error) bool {
+	var fieldErr *FieldError
+	var recordErr *RecordError
+	var controlErr *ControlTotalError
+	return errors.As(err, &fieldErr) || errors.As(err, &recordErr) || errors.As(err, &controlErr)
+}
@@ -0,0 +1,165 @@
+package fixedwidth
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type order struct {
+	ID       string `fixed:"4"`
+	Customer customer
+}
+
+type customer struct {
+	Name string `fixed:"6"`
+	Zip  int    `fixed:"5,right"`
+}
+
+func TestUnmarshal_FieldError(t *testing.T) {
+	data := "0001Huy   70000\n0002Lâm   7x000"
+
+	var orders []order
+	err := Unmarshal([]byte(data), &orders)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("Unmarshal() error = %v, want *FieldError", err)
+	}
+	want := FieldError{Line: 2, Offset: 10, Field: "Customer.Zip", Value: "7x000"}
+	if fieldErr.Line != want.Line || fieldErr.Offset != want.Offset ||
+		fieldErr.Field != want.Field || fieldErr.Value != want.Value {
+		t.Errorf("Unmarshal() error = %+v, want %+v", fieldErr, want)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Unmarshal() error = %v, want wrapped %v", err, strconv.ErrSyntax)
+	}
+	if got, want := err.Error(), `line 2, offset 10, field Customer.Zip: cannot parse "7x000": strconv.ParseInt: parsing "7x000": invalid syntax`; got != want {
+		t.Errorf("Error() got = %s, want %s", got, want)
+	}
+}
+
+func TestUnmarshal_FieldError_OutOfRange(t *testing.T) {
+	var small struct {
+		Signed   int8   `fixed:"3"`
+		Unsigned uint16 `fixed:"5"`
+	}
+
+	tests := []struct {
+		data  string
+		field string
+	}{
+		{data: "300    1", field: "Signed"},
+		{data: "1  70000", field: "Unsigned"},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.data), &small)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != tt.field || !errors.Is(err, strconv.ErrRange) {
+			t.Errorf("Unmarshal(%q) error = %v, want a FieldError of %s wrapping %v", tt.data, err, tt.field, strconv.ErrRange)
+		}
+	}
+}
+
+func TestUnmarshal_RecordError(t *testing.T) {
+	var numbers []int
+	err := Unmarshal([]byte("1\n2\nthree"), &numbers)
+
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) {
+		t.Fatalf("Unmarshal() error = %v, want *RecordError", err)
+	}
+	if recordErr.Line != 3 {
+		t.Errorf("Line got = %d, want 3", recordErr.Line)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Unmarshal() error = %v, want wrapped %v", err, strconv.ErrSyntax)
+	}
+}
+
+func TestUnmarshal_ContinueOnError(t *testing.T) {
+	data := "0001Huy   70000\n0002Lâm   7x000\n0003Minh  10000\n0004Nam   abc  "
+
+	tests := []struct {
+		name      string
+		maxErrors int
+		want      []order
+		wantLines []int
+		wantAbort bool
+	}{
+		{
+			name: "no limit",
+			want: []order{
+				{ID: "0001", Customer: customer{Name: "Huy", Zip: 70000}},
+				{ID: "0003", Customer: customer{Name: "Minh", Zip: 10000}},
+			},
+			wantLines: []int{2, 4},
+		},
+		{
+			name:      "stop after max errors",
+			maxErrors: 1,
+			want: []order{
+				{ID: "0001", Customer: customer{Name: "Huy", Zip: 70000}},
+			},
+			wantLines: []int{2},
+			wantAbort: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewUnmarshaler()
+			u.ContinueOnError = true
+			u.MaxErrors = tt.maxErrors
+
+			var got []order
+			err := u.Unmarshal([]byte(data), &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", got, tt.want)
+			}
+
+			var errs ErrorList
+			if !errors.As(err, &errs) {
+				t.Fatalf("Unmarshal() error = %v, want ErrorList", err)
+			}
+			if tt.wantAbort != errors.Is(errs[len(errs)-1], ErrTooManyErrors) {
+				t.Errorf("Unmarshal() error = %v, aborted want %v", err, tt.wantAbort)
+			}
+			// the errors of the list are found without ranging over it
+			var first *FieldError
+			if !errors.As(err, &first) || first.Line != tt.wantLines[0] {
+				t.Errorf("errors.As() got = %v, want the FieldError of line %d", first, tt.wantLines[0])
+			}
+			if !errors.Is(err, strconv.ErrSyntax) {
+				t.Errorf("errors.Is() got false for %v, want true", err)
+			}
+			var lines []int
+			for _, err := range errs {
+				var fieldErr *FieldError
+				if errors.As(err, &fieldErr) {
+					lines = append(lines, fieldErr.Line)
+				}
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("failed lines got = %v, want %v", lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_ContinueOnError_InvalidStruct(t *testing.T) {
+	u := NewUnmarshaler()
+	u.ContinueOnError = true
+
+	var got []struct {
+		Name string `fixed:"abc"`
+	}
+	err := u.Unmarshal([]byte("a\nb"), &got)
+	if err == nil {
+		t.Fatal("Unmarshal() expected error")
+	}
+	if _, ok := err.(ErrorList); ok {
+		t.Errorf("Unmarshal() error = %v, invalid struct should not be collected", err)
+	}
+}
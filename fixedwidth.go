@@ -2,6 +2,20 @@ package fixedwidth
 
 const spaceByte = byte(' ')
 
+// FixedWidthMarshaler is the interface implemented by types that can marshal themselves
+// into a fixed-width field. The result is still limited by the width of the field,
+// padded or truncated like any other value.
+type FixedWidthMarshaler interface {
+	MarshalFixedWidth() ([]byte, error)
+}
+
+// FixedWidthUnmarshaler is the interface implemented by types that can unmarshal
+// a fixed-width field of themselves. data is the raw field, padding included;
+// it must be copied if it is kept after returning.
+type FixedWidthUnmarshaler interface {
+	UnmarshalFixedWidth(data []byte) error
+}
+
 // Marshal see Marshal method of Marshaler
 func Marshal(v interface{}) ([]byte, error) {
 	return NewMarshaler().Marshal(v)
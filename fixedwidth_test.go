@@ -111,3 +111,25 @@ type mixedStructForUnmarshal struct {
 	F29 float64  `fixed:"5"`
 	F30 *string  `fixed:"2"`
 }
+
+type alignedStruct struct {
+	Name   string  `fixed:"6"`
+	Amount int     `fixed:"6,right"`
+	Code   string  `fixed:"5,center"`
+	Rate   float64 `fixed:"7,right"`
+}
+
+type paddedStruct struct {
+	Name   string  `fixed:"6"`
+	Amount int     `fixed:"6,pad=0"`
+	Code   string  `fixed:"5,pad=*"`
+	Rate   float64 `fixed:"7,pad=0"`
+	Note   string  `fixed:"4,right,pad=·"`
+}
+
+type columnStruct struct {
+	Amount int    `fixed:"15-20,right"`
+	Name   string `fixed:"1-8"`
+	Code   string `fixed:"10-11"`
+	Note   string `fixed:"3"`
+}
@@ -0,0 +1,225 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"unicode/utf8"
+)
+
+// nextRecord returns the first record of data, decoded into a value of type t, and the data following it.
+// more is false for the last record of data.
+//
+// Records end with the Terminator of the Unmarshaler, or take the width of their struct type
+// if the Terminator is empty. A nil t means the type registered for the code of the record.
+func (d *decodeState) nextRecord(data []byte, t reflect.Type) (record, rest []byte, more bool, err error) {
+	if len(d.Terminator) > 0 {
+		record = data
+		if i := bytes.Index(data, d.Terminator); i >= 0 {
+			// a terminator at the end of data does not begin another record
+			record, rest = data[:i], data[i+len(d.Terminator):]
+			more = len(rest) > 0
+		}
+		if d.crlf() {
+			record = bytes.TrimSuffix(record, []byte("\r"))
+		}
+		return record, rest, more, nil
+	}
+
+	p, err := d.recordPlan(data, t)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	n, complete := p.length(data, d.byteMode())
+	if !complete && len(data) > 0 {
+		return nil, nil, false, d.shortRecord()
+	}
+	return data[:n], data[n:], n < len(data), nil
+}
+
+// crlf reports whether records ending with \r\n are accepted as well, when the Terminator is \n
+func (m Unmarshaler) crlf() bool {
+	return len(m.Terminator) == 1 && m.Terminator[0] == '\n'
+}
+
+// trimTerminator removes the Terminator at the end of data, and the carriage return before it when crlf is set
+func (m Unmarshaler) trimTerminator(data []byte) []byte {
+	if len(m.Terminator) == 0 || !bytes.HasSuffix(data, m.Terminator) {
+		return data
+	}
+	data = data[:len(data)-len(m.Terminator)]
+	if m.crlf() {
+		data = bytes.TrimSuffix(data, []byte("\r"))
+	}
+	return data
+}
+
+// skipRecord reports whether the record is an empty line skipped by SkipBlankLines
+func (m Unmarshaler) skipRecord(record []byte) bool {
+	return m.SkipBlankLines && len(record) == 0
+}
+
+// recordPlan returns the plan of a fixed-length record starting at data, decoded into a value of type t.
+// Records decoded into an interface, or a nil t, follow the type registered for their code.
+func (d *decodeState) recordPlan(data []byte, t reflect.Type) (*typePlan, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if (t == nil || t.Kind() == reflect.Interface) && d.Registry != nil {
+		code := d.recordCode(data)
+		registered, ok := d.Registry.types[string(code)]
+		if !ok {
+			return nil, &RecordError{Line: d.line, Err: fmt.Errorf("unknown record type %q", code)}
+		}
+		t = structType(registered)
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("records without terminator require a struct type, got %v", t)
+	}
+
+	p, err := planFor(t)
+	if err != nil {
+		return nil, err
+	}
+	if p.width == 0 {
+		return nil, fmt.Errorf("records of type %s without terminator have no width", t)
+	}
+	return p, nil
+}
+
+// shortRecord returns the error of a last record shorter than its width
+func (d *decodeState) shortRecord() error {
+	return &RecordError{Line: d.line, Err: errors.New("the record is shorter than its width")}
+}
+
+// segment is a part of a record: binary fields are counted in bytes, the text in the width mode
+type segment struct {
+	width  int
+	binary bool
+}
+
+// segments returns the parts of a record of plan p in order
+func (p *typePlan) segments() []segment {
+	var segs []segment
+	pos := 0
+	for _, f := range p.fields {
+		if f.offset > pos {
+			segs = append(segs, segment{width: f.offset - pos})
+		}
+		segs = append(segs, segment{width: f.span, binary: f.usage != usageDisplay})
+		pos = f.offset + f.span
+	}
+	if p.width > pos {
+		segs = append(segs, segment{width: p.width - pos})
+	}
+	return segs
+}
+
+// length returns the length in bytes of the record of plan p at the beginning of data, counting the text in mode.
+// complete is false if data ends before the record.
+func (p *typePlan) length(data []byte, mode WidthMode) (n int, complete bool) {
+	for _, s := range p.segments() {
+		if s.binary || mode == Bytes {
+			if n+s.width > len(data) {
+				return len(data), false
+			}
+			n += s.width
+			continue
+		}
+
+		k, width := mode.prefix(data[n:], s.width)
+		n += k
+		if width < s.width && n >= len(data) {
+			return n, false
+		}
+	}
+	return n, true
+}
+
+// recordCode returns the code of the record data, following the Registry
+func (d *decodeState) recordCode(data []byte) []byte {
+	if d.Charset != nil {
+		return d.Charset.decode(d.Registry.code(data, Bytes))
+	}
+	return d.Registry.code(data, d.WidthMode)
+}
+
+// byteMode returns the unit of the width of the encoded records, bytes with a Charset
+func (m Unmarshaler) byteMode() WidthMode {
+	if m.Charset != nil {
+		return Bytes
+	}
+	return m.WidthMode
+}
+
+// skipBlankLines discards the empty lines at the current position of the input stream, following SkipBlankLines
+func (d *Decoder) skipBlankLines() {
+	if !d.SkipBlankLines || len(d.Terminator) == 0 {
+		return
+	}
+
+	for {
+		n := len(d.Terminator)
+		if b, _ := d.r.Peek(n); !bytes.Equal(b, d.Terminator) {
+			if b, _ := d.r.Peek(2); !d.crlf() || string(b) != "\r\n" {
+				return
+			}
+			n = 2
+		}
+		_, _ = d.r.Discard(n)
+		d.line++
+	}
+}
+
+// readFixedRecord reads a record of plan p from the input stream, counting the text in mode.
+// It returns io.ErrUnexpectedEOF if the input ends before the record.
+func (d *Decoder) readFixedRecord(p *typePlan, mode WidthMode) error {
+	for _, s := range p.segments() {
+		if err := d.readSegment(s, mode); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readSegment reads a part of a record from the input stream
+func (d *Decoder) readSegment(s segment, mode WidthMode) error {
+	if s.binary || mode == Bytes {
+		for i := 0; i < s.width; i++ {
+			c, err := d.r.ReadByte()
+			if err != nil {
+				return err
+			}
+			d.buf = append(d.buf, c)
+		}
+		return nil
+	}
+
+	for w := 0; ; {
+		r, size, err := d.r.ReadRune()
+		if err == io.EOF && w == s.width {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rw := mode.runeWidth(r, size)
+		if w+rw > s.width {
+			return d.r.UnreadRune()
+		}
+		if r == utf8.RuneError && size == 1 {
+			// keep the invalid byte as is
+			_ = d.r.UnreadRune()
+			c, _ := d.r.ReadByte()
+			d.buf = append(d.buf, c)
+		} else {
+			d.buf = appendRune(d.buf, r)
+		}
+		w += rw
+	}
+}
@@ -0,0 +1,354 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var framingPeople = []person{
+	{FirstName: "Huy", LastName: "Đặng", Age: 25, Job: "Engineer"},
+	{FirstName: "Didier", LastName: "Drogba", Age: 41, Job: "Retired"},
+}
+
+func TestTerminator(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminator []byte
+		want       string
+	}{
+		{
+			name:       "new line",
+			terminator: []byte("\n"),
+			want:       "Huy       Đặng      25  Engineer\nDidier    Drogba    41  Retired ",
+		},
+		{
+			name:       "carriage return and new line",
+			terminator: []byte("\r\n"),
+			want:       "Huy       Đặng      25  Engineer\r\nDidier    Drogba    41  Retired ",
+		},
+		{
+			name:       "custom",
+			terminator: []byte("|#|"),
+			want:       "Huy       Đặng      25  Engineer|#|Didier    Drogba    41  Retired ",
+		},
+		{
+			name: "fixed length",
+			want: "Huy       Đặng      25  EngineerDidier    Drogba    41  Retired ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.Terminator = tt.terminator
+			got, err := m.Marshal(framingPeople)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.want)
+			}
+
+			u := NewUnmarshaler()
+			u.Terminator = tt.terminator
+			var people []person
+			if err := u.Unmarshal([]byte(tt.want), &people); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(people, framingPeople) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", people, framingPeople)
+			}
+
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			e.Terminator = tt.terminator
+			if err := e.Encode(framingPeople); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.want + string(tt.terminator); buf.String() != want {
+				t.Errorf("Encode() got = %q, want %q", buf.String(), want)
+			}
+
+			d := NewDecoder(strings.NewReader(tt.want))
+			d.Terminator = tt.terminator
+			for _, want := range framingPeople {
+				var p person
+				if err := d.Decode(&p); err != nil {
+					t.Fatal(err)
+				}
+				if p != want {
+					t.Errorf("Decode() got = %+v, want %+v", p, want)
+				}
+			}
+			var p person
+			if err := d.Decode(&p); err != io.EOF {
+				t.Errorf("Decode() got error %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestTerminator_FixedLengthRegistry(t *testing.T) {
+	r := newRegistry(t, 1, 2, batchTypes)
+	data := strings.Replace(batchData, "\n", "", -1)
+
+	m := NewMarshaler()
+	m.Registry = r
+	m.Terminator = nil
+	got, err := m.Marshal(batchRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("Marshal() got = %q, want %q", got, data)
+	}
+
+	u := NewUnmarshaler()
+	u.Registry = r
+	u.Terminator = nil
+	var records []interface{}
+	if err := u.Unmarshal([]byte(data), &records); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, batchRecords) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", records, batchRecords)
+	}
+
+	d := NewDecoder(strings.NewReader(data))
+	d.Registry = r
+	d.Terminator = nil
+	for _, want := range batchRecords {
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("Decode() got = %+v, want %+v", v, want)
+		}
+	}
+
+	// documents are read by the widths of their records
+	docs := newRegistry(t, 1, 1, docTypes)
+	u.Registry = docs
+	var file docFile
+	if err := u.Unmarshal([]byte(strings.Replace(docData, "\n", "", -1)), &file); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file, docValue) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", file, docValue)
+	}
+}
+
+func TestTerminator_FixedLengthErrors(t *testing.T) {
+	u := NewUnmarshaler()
+	u.Terminator = nil
+	var names []string
+	if err := u.Unmarshal([]byte("abc"), &names); err == nil {
+		t.Error("Unmarshal() expected error for records without a struct type")
+	}
+
+	// the records following an unknown record type cannot be located
+	d := NewDecoder(strings.NewReader("01ACME    07XYZ99002"))
+	d.Registry = newRegistry(t, 1, 2, batchTypes)
+	d.Terminator = nil
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(&v); err == nil {
+		t.Error("Decode() expected error for an unknown record type")
+	}
+	if d.More() {
+		t.Error("More() got true after an unknown record type")
+	}
+}
+
+type lineEndingRecord struct {
+	Name  string `fixed:"5"`
+	Count int    `fixed:"2"`
+}
+
+func TestLineEndings_SingleRecord(t *testing.T) {
+	type note struct {
+		Code string `fixed:"4"`
+		Note string `fixed:"5"`
+	}
+	want := note{Code: "abcd", Note: "hello"}
+
+	for _, data := range []string{"abcdhello", "abcdhello\n", "abcdhello\r\n"} {
+		var got note
+		if err := Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Unmarshal(%q) got = %+v, want %+v", data, got, want)
+		}
+		var p *note
+		if err := Unmarshal([]byte(data), &p); err != nil {
+			t.Fatal(err)
+		}
+		if p == nil || *p != want {
+			t.Errorf("Unmarshal(%q) got = %+v, want %+v", data, p, want)
+		}
+	}
+
+	// data without records
+	for _, data := range []string{"", "\n", "\r\n"} {
+		var got []note
+		if err := Unmarshal([]byte(data), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 0 {
+			t.Errorf("Unmarshal(%q) got = %+v, want no records", data, got)
+		}
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	want := []lineEndingRecord{{Name: "alpha", Count: 1}, {Name: "beta", Count: 2}, {Name: "gamma", Count: 3}}
+
+	tests := []struct {
+		name string
+		data string
+		skip bool
+	}{
+		{name: "crlf", data: "alpha1 \r\nbeta 2 \r\ngamma3 "},
+		{name: "mixed", data: "alpha1 \r\nbeta 2 \ngamma3 \r\n"},
+		{name: "trailing terminator", data: "alpha1 \nbeta 2 \ngamma3 \n"},
+		{name: "blank lines", data: "\nalpha1 \n\r\nbeta 2 \n\ngamma3 \n\n", skip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewUnmarshaler()
+			u.SkipBlankLines = tt.skip
+			var got []lineEndingRecord
+			if err := u.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", got, want)
+			}
+
+			d := NewDecoder(strings.NewReader(tt.data))
+			d.SkipBlankLines = tt.skip
+			got = nil
+			for d.More() {
+				var r lineEndingRecord
+				if err := d.Decode(&r); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, r)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode() got = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// skipped lines are still counted
+	u := NewUnmarshaler()
+	u.SkipBlankLines = true
+	var records []lineEndingRecord
+	err := u.Unmarshal([]byte("alpha1 \r\n\r\n\r\nbeta x \r\n"), &records)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Line != 4 {
+		t.Errorf("Unmarshal() got error %v, want a FieldError on line 4", err)
+	}
+
+	d := NewDecoder(strings.NewReader("alpha1 \n\n\nbeta x \n"))
+	d.SkipBlankLines = true
+	var r lineEndingRecord
+	if err := d.Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(&r); !errors.As(err, &fieldErr) || fieldErr.Line != 4 {
+		t.Errorf("Decode() got error %v, want a FieldError on line 4", err)
+	}
+
+	m := NewMarshaler()
+	m.Terminator = []byte("\r\n")
+	m.TrailingTerminator = true
+	got, err := m.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := "alpha1 \r\nbeta 2 \r\ngamma3 \r\n"; string(got) != data {
+		t.Errorf("Marshal() got = %q, want %q", got, data)
+	}
+}
+
+type binaryFramingRecord struct {
+	A int32  `fixed:"4,comp"`
+	B string `fixed:"2"`
+}
+
+func TestTerminator_FixedLengthBinary(t *testing.T) {
+	// 0xC3A9 is encoded as bytes which form the UTF-8 character é
+	want := []binaryFramingRecord{{A: 0xC3A9, B: "xy"}, {A: 10, B: "zw"}}
+
+	m := NewMarshaler()
+	m.Terminator = nil
+	data, err := m.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 12 {
+		t.Fatalf("Marshal() got %d bytes, want 12", len(data))
+	}
+
+	u := NewUnmarshaler()
+	u.Terminator = nil
+	var got []binaryFramingRecord
+	if err := u.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", got, want)
+	}
+
+	d := NewDecoder(bytes.NewReader(data))
+	d.Terminator = nil
+	for _, w := range want {
+		var r binaryFramingRecord
+		if err := d.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r != w {
+			t.Errorf("Decode() got = %+v, want %+v", r, w)
+		}
+	}
+}
+
+func TestTerminator_FixedLengthShortRecord(t *testing.T) {
+	data := "Huy       Đặng      25  EngineerDidier    Drogba    41"
+
+	u := NewUnmarshaler()
+	u.Terminator = nil
+	var people []person
+	err := u.Unmarshal([]byte(data), &people)
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) || recordErr.Line != 2 {
+		t.Errorf("Unmarshal() got error %v, want a RecordError on line 2", err)
+	}
+
+	d := NewDecoder(strings.NewReader(data))
+	d.Terminator = nil
+	var p person
+	if err := d.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Decode(&p); !errors.As(err, &recordErr) || recordErr.Line != 2 {
+		t.Errorf("Decode() got error %v, want a RecordError on line 2", err)
+	}
+	if err := d.Decode(&p); err != io.EOF {
+		t.Errorf("Decode() got error %v, want io.EOF", err)
+	}
+}
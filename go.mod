@@ -1,5 +1,5 @@
 module github.com/huydang284/fixedwidth
 
-go 1.12
+go 1.13
 
 require github.com/google/go-cmp v0.3.1
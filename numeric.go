@@ -0,0 +1,239 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// appendImpliedFloat appends f with the given number of decimals and without the decimal point,
+// e.g. 12.34 with 2 decimals is 1234.
+func appendImpliedFloat(b []byte, f float64, decimals, bitSize int) []byte {
+	start := len(b)
+	b = strconv.AppendFloat(b, f, 'f', decimals, bitSize)
+	if i := bytes.IndexByte(b[start:], '.'); i >= 0 {
+		b = append(b[:start+i], b[start+i+1:]...)
+	}
+
+	// remove the leading zeros of numbers below 1, 0.05 is 5
+	digits := start
+	if hasSign(b[start:]) {
+		digits++
+	}
+	zeros := 0
+	for digits+zeros < len(b)-1 && b[digits+zeros] == '0' {
+		zeros++
+	}
+	return append(b[:digits], b[digits+zeros:]...)
+}
+
+// impliedDecimal inserts the decimal point in data, the digits of a number with implied decimals.
+// data containing a decimal point is returned as is.
+func impliedDecimal(data []byte, decimals int) []byte {
+	if decimals == 0 || bytes.IndexByte(data, '.') >= 0 {
+		return data
+	}
+
+	sign := 0
+	if hasSign(data) {
+		sign = 1
+	}
+	digits := data[sign:]
+
+	b := make([]byte, 0, len(data)+decimals+2)
+	b = append(b, data[:sign]...)
+	if len(digits) <= decimals {
+		b = append(b, '0', '.')
+		b = append(b, bytes.Repeat([]byte{'0'}, decimals-len(digits))...)
+		return append(b, digits...)
+	}
+	b = append(b, digits[:len(digits)-decimals]...)
+	b = append(b, '.')
+	return append(b, digits[len(digits)-decimals:]...)
+}
+
+// impliedInteger returns the digits of an integer with implied decimals,
+// an explicit decimal point is accepted, e.g. 12.3 with 2 decimals is 1230.
+func impliedInteger(data []byte, decimals int) ([]byte, error) {
+	i := bytes.IndexByte(data, '.')
+	if i < 0 {
+		return data, nil
+	}
+
+	fraction := data[i+1:]
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("%q has more than %d decimals", data, decimals)
+	}
+
+	b := make([]byte, 0, len(data)+decimals)
+	b = append(b, data[:i]...)
+	b = append(b, fraction...)
+	return append(b, bytes.Repeat([]byte{'0'}, decimals-len(fraction))...), nil
+}
+
+// floatFormat returns the format and precision of a float field, see strconv.FormatFloat.
+// The default is two decimals, or the smallest precision representing the value exactly
+// for the e and g formats.
+func (o tagOptions) floatFormat() (byte, int) {
+	format, prec := o.format, 2
+	if format == 0 {
+		format = 'f'
+	} else if format != 'f' {
+		prec = -1
+	}
+	if o.hasPrec {
+		prec = o.prec
+	}
+	return format, prec
+}
+
+// signPosition is the place of the sign of a number in its field
+type signPosition int
+
+const (
+	// signLeading puts the sign in front of the digits, only for negative numbers unless plus is set
+	signLeading signPosition = iota
+	// signTrailing puts the sign after the digits, only for negative numbers unless plus is set
+	signTrailing
+	// signLeadingSeparate always puts the sign in the first column of the field
+	signLeadingSeparate
+	// signTrailingSeparate always puts the sign in the last column of the field
+	signTrailingSeparate
+)
+
+// signPositions maps the values of the sign option of the fixed tag
+var signPositions = map[string]signPosition{
+	"leading":           signLeading,
+	"trailing":          signTrailing,
+	"leading-separate":  signLeadingSeparate,
+	"trailing-separate": signTrailingSeparate,
+}
+
+// hasSignOptions reports whether the sign or plus option of the fixed tag is set
+func (o tagOptions) hasSignOptions() bool {
+	return o.sign != signLeading || o.plus
+}
+
+// separate reports whether the sign has its own column at the edge of the field
+func (p signPosition) separate() bool {
+	return p == signLeadingSeparate || p == signTrailingSeparate
+}
+
+// placeSign moves the sign of the number b[start:] to the position set by opts
+func placeSign(b []byte, start int, opts tagOptions) []byte {
+	if !opts.hasSignOptions() {
+		return b
+	}
+
+	sign := byte(0)
+	if hasSign(b[start:]) {
+		sign = b[start]
+		b = append(b[:start], b[start+1:]...)
+	}
+	if sign != '-' && (opts.plus || opts.sign.separate()) {
+		sign = '+'
+	}
+	if sign == 0 {
+		return b
+	}
+
+	if opts.sign == signTrailing || opts.sign == signTrailingSeparate {
+		return append(b, sign)
+	}
+	b = append(b, 0)
+	copy(b[start+1:], b[start:])
+	b[start] = sign
+	return b
+}
+
+// splitSign returns the sign and the digits of data, the raw number of a field with the sign option set.
+// sign is zero if data has no sign, digits still hold the padding of a number with an attached sign.
+func splitSign(data []byte, opts tagOptions) (sign byte, digits []byte) {
+	switch opts.sign {
+	case signLeadingSeparate:
+		if hasSign(data) {
+			return data[0], data[1:]
+		}
+	case signTrailingSeparate:
+		if n := len(data); n > 0 && (data[n-1] == '-' || data[n-1] == '+') {
+			return data[n-1], data[:n-1]
+		}
+	}
+	return 0, data
+}
+
+// leadingSign returns the number data, which padding is already removed, with its sign in front
+// of the digits as expected by strconv; a positive sign is dropped.
+func leadingSign(data []byte, sign byte, opts tagOptions) []byte {
+	if sign == 0 {
+		switch {
+		case hasSign(data):
+			sign, data = data[0], data[1:]
+		case opts.sign == signTrailing && hasSign(data[len(data)-1:]):
+			sign, data = data[len(data)-1], data[:len(data)-1]
+		}
+	}
+
+	if sign != '-' || len(data) == 0 {
+		return data
+	}
+	b := make([]byte, 0, len(data)+1)
+	b = append(b, '-')
+	return append(b, data...)
+}
+
+// The last digit of a zoned decimal holds the sign of the number,
+// e.g. 123 is 12C and -123 is 12L.
+const (
+	positiveOverpunch = "{ABCDEFGHI"
+	negativeOverpunch = "}JKLMNOPQR"
+)
+
+// overpunch replaces the sign and the last digit of the number b[start:] by the zoned decimal digit.
+// A number which does not end with a digit, such as NaN, is an error.
+func overpunch(b []byte, start int) ([]byte, error) {
+	negative := false
+	if hasSign(b[start:]) {
+		negative = b[start] == '-'
+		b = append(b[:start], b[start+1:]...)
+	}
+
+	last := len(b) - 1
+	if last < start {
+		return b, nil
+	}
+	if b[last] < '0' || b[last] > '9' {
+		return b, fmt.Errorf("%s cannot be written as a zoned decimal", b[start:])
+	}
+	if negative {
+		b[last] = negativeOverpunch[b[last]-'0']
+	} else {
+		b[last] = positiveOverpunch[b[last]-'0']
+	}
+	return b, nil
+}
+
+// unpunch returns the zoned decimal data, which padding is already removed,
+// with a leading sign as expected by strconv. A plain last digit is positive.
+func unpunch(data []byte) ([]byte, error) {
+	last := len(data) - 1
+	c := data[last]
+	digit, negative := byte(0), false
+	if i := strings.IndexByte(positiveOverpunch, c); i >= 0 {
+		digit = '0' + byte(i)
+	} else if i := strings.IndexByte(negativeOverpunch, c); i >= 0 {
+		digit, negative = '0'+byte(i), true
+	} else if c >= '0' && c <= '9' {
+		digit = c
+	} else {
+		return nil, fmt.Errorf("invalid zoned decimal digit %q", c)
+	}
+
+	b := make([]byte, 0, len(data)+1)
+	if negative {
+		b = append(b, '-')
+	}
+	b = append(b, data[:last]...)
+	return append(b, digit), nil
+}
@@ -0,0 +1,264 @@
+package fixedwidth
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type premium struct {
+	Amount float64 `fixed:"7,implied=2,pad=0"`
+	Cents  int64   `fixed:"6,implied=2,right"`
+	Rate   float32 `fixed:"5,implied=4"`
+	Units  uint    `fixed:"4,implied=1,pad=0"`
+}
+
+func TestImplied(t *testing.T) {
+	tests := []struct {
+		name string
+		v    premium
+		data string
+	}{
+		{
+			name: "values",
+			v:    premium{Amount: 12.34, Cents: 1234, Rate: 0.0525, Units: 15},
+			data: "0001234  1234525  0015",
+		},
+		{
+			name: "negative",
+			v:    premium{Amount: -0.05, Cents: -7},
+			data: "-000005    -70    0000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.data)
+			}
+
+			var back premium
+			if err := Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, tt.v)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_ImpliedExplicitDecimal(t *testing.T) {
+	var got premium
+	if err := Unmarshal([]byte("00012.3  12.3     01.5"), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := premium{Amount: 12.3, Cents: 1230, Units: 15}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", got, want)
+	}
+
+	if err := Unmarshal([]byte("0000000 1.234"), &got); err == nil {
+		t.Error("Unmarshal() expected error for too many decimals")
+	}
+}
+
+func Test_impliedDecimal(t *testing.T) {
+	tests := []struct {
+		data     string
+		decimals int
+		want     string
+	}{
+		{"1234", 2, "12.34"},
+		{"5", 2, "0.05"},
+		{"-5", 3, "-0.005"},
+		{"+1234", 0, "+1234"},
+		{"12.5", 2, "12.5"},
+	}
+
+	for _, tt := range tests {
+		if got := impliedDecimal([]byte(tt.data), tt.decimals); string(got) != tt.want {
+			t.Errorf("impliedDecimal(%q, %d) got = %q, want %q", tt.data, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+type position struct {
+	Lat   float64 `fixed:"10,prec=6,right"`
+	Lng   float64 `fixed:"11,prec=6,right"`
+	Mass  float64 `fixed:"9,fmt=e,prec=2"`
+	Ratio float32 `fixed:"6,fmt=g"`
+	Scale float64 `fixed:"4,prec=0,strict"`
+}
+
+func TestFloatFormat(t *testing.T) {
+	v := position{Lat: 10.776889, Lng: 106.700806, Mass: 5972.4, Ratio: 0.125, Scale: 1000}
+	data := " 10.776889 106.7008065.97e+03 0.125 1000"
+
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("Marshal() got = %q, want %q", got, data)
+	}
+
+	var back position
+	if err := Unmarshal([]byte(data), &back); err != nil {
+		t.Fatal(err)
+	}
+	want := v
+	want.Mass = 5970
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", back, want)
+	}
+}
+
+func TestMarshal_Strict(t *testing.T) {
+	_, err := Marshal(position{Scale: 12345})
+	if err == nil {
+		t.Fatal("Marshal() expected error for a value longer than the width")
+	}
+
+	v := struct {
+		Amount int `fixed:"3,strict"`
+	}{1000}
+	if _, err := Marshal(v); err == nil {
+		t.Error("Marshal() expected error for an integer longer than the width")
+	}
+}
+
+type ledger struct {
+	Leading          int     `fixed:"6,right"`
+	Plus             int     `fixed:"6,pad=0,plus"`
+	Trailing         int     `fixed:"6,pad=0,sign=trailing"`
+	LeadingSeparate  int     `fixed:"6,right,sign=leading-separate"`
+	TrailingSeparate float64 `fixed:"7,implied=2,sign=trailing-separate"`
+	Unsigned         uint    `fixed:"4,plus"`
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name string
+		v    ledger
+		data string
+	}{
+		{
+			name: "negative",
+			v:    ledger{Leading: -12, Plus: -12, Trailing: -12, LeadingSeparate: -12, TrailingSeparate: -1.5},
+			data: "   -12-00012" + "00012-" + "-   12" + "150   -" + "+0  ",
+		},
+		{
+			name: "positive",
+			v:    ledger{Leading: 12, Plus: 12, Trailing: 12, LeadingSeparate: 12, TrailingSeparate: 1.5, Unsigned: 7},
+			data: "    12+00012" + "000012" + "+   12" + "150   +" + "+7  ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.data)
+			}
+
+			var back ledger
+			if err := Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, tt.v)
+			}
+		})
+	}
+
+	var got ledger
+	if err := Unmarshal([]byte("   12-"), &got); err == nil {
+		t.Error("Unmarshal() expected error for a misplaced trailing sign")
+	}
+}
+
+type zonedRecord struct {
+	Amount  float64 `fixed:"9,zoned,implied=2"` // PIC S9(7)V99
+	Count   int     `fixed:"5,zoned"`
+	Units   uint    `fixed:"3,zoned"`
+	Balance int64   `fixed:"6,zoned,pad= ,right"`
+}
+
+func TestZoned(t *testing.T) {
+	tests := []struct {
+		name string
+		v    zonedRecord
+		data string
+	}{
+		{
+			name: "positive",
+			v:    zonedRecord{Amount: 12345.67, Count: 120, Units: 9, Balance: 1},
+			data: "00123456G" + "0012{" + "00I" + "     A",
+		},
+		{
+			name: "negative",
+			v:    zonedRecord{Amount: -0.01, Count: -7, Balance: -250},
+			data: "00000000J" + "0000P" + "00{" + "   25}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.data)
+			}
+
+			var back zonedRecord
+			if err := Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.v) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, tt.v)
+			}
+		})
+	}
+}
+
+func TestMarshal_ZonedErrors(t *testing.T) {
+	for _, x := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if got, err := Marshal(zonedRecord{Amount: x}); err == nil {
+			t.Errorf("Marshal(%v) got = %q, want error", x, got)
+		}
+	}
+}
+
+func TestUnmarshal_ZonedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid digit", data: "00000001X"},
+		{name: "negative unsigned", data: "000000000" + "00000" + "00J"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got zonedRecord
+			if err := Unmarshal([]byte(tt.data), &got); err == nil {
+				t.Error("Unmarshal() expected error")
+			}
+		})
+	}
+
+	var got zonedRecord
+	if err := Unmarshal([]byte("000001234"), &got); err != nil || got.Amount != 12.34 {
+		t.Errorf("Unmarshal() unsigned digits got = %v, %v, want 12.34", got.Amount, err)
+	}
+}
@@ -0,0 +1,135 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// ErrOverflow is the underlying error of a FieldError for a value longer than its field
+var ErrOverflow = errors.New("value exceeds the width of the field")
+
+// Overflow is the handling of values longer than their field
+type Overflow int
+
+const (
+	// OverflowDefault truncates text and reports numbers as errors,
+	// floats are rounded to the decimals which fit.
+	OverflowDefault Overflow = iota
+
+	// OverflowTruncate keeps the leftmost characters
+	OverflowTruncate
+
+	// OverflowError fails with a FieldError wrapping ErrOverflow
+	OverflowError
+
+	// OverflowTruncateLeft keeps the rightmost characters, and the sign of a number
+	OverflowTruncateLeft
+
+	// OverflowMark fills the field with the marker of the Marshaler
+	OverflowMark
+)
+
+// overflows maps the values of the overflow option of the fixed tag
+var overflows = map[string]Overflow{
+	"truncate": OverflowTruncate,
+	"error":    OverflowError,
+	"left":     OverflowTruncateLeft,
+	"mark":     OverflowMark,
+}
+
+// defaultOverflowMarker fills the overflowing fields unless the Marshaler sets a marker
+const defaultOverflowMarker = '*'
+
+// overflowFor returns the handling of a value of field f longer than the field
+func (m *Marshaler) overflowFor(f *fieldPlan) Overflow {
+	if f.strict {
+		return OverflowError
+	}
+	if f.overflow != OverflowDefault {
+		return f.overflow
+	}
+	return m.Overflow
+}
+
+// overflow handles the value of field f from lowerBound, which is wider than limit.
+// It returns whether the field is already filled.
+func (m *Marshaler) overflow(f *fieldPlan, startOffset, lowerBound, limit int) (bool, error) {
+	switch m.overflowFor(f) {
+	case OverflowDefault:
+		if f.numeric && !m.roundFraction(lowerBound, limit) {
+			return false, m.overflowError(f, startOffset)
+		}
+	case OverflowError:
+		return false, m.overflowError(f, startOffset)
+	case OverflowTruncateLeft:
+		if f.numeric && lowerBound == startOffset && hasSign(m.b[lowerBound:]) {
+			lowerBound, limit = lowerBound+1, limit-1
+		}
+		b := m.b[lowerBound:]
+		n := m.widthMode().suffix(b, limit)
+		m.b = m.b[:lowerBound+copy(b, b[len(b)-n:])]
+	case OverflowMark:
+		marker := m.OverflowMarker
+		if marker == 0 {
+			marker = defaultOverflowMarker
+		}
+		m.b = m.b[:startOffset]
+		m.appendPadding(f.width, marker)
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *Marshaler) overflowError(f *fieldPlan, startOffset int) error {
+	return &FieldError{
+		Line:   m.line,
+		Offset: f.offset,
+		Field:  f.name,
+		Value:  string(m.b[startOffset:]),
+		Err:    ErrOverflow,
+		encode: true,
+	}
+}
+
+// roundFraction rounds the decimal number from lowerBound to the decimals which fit in limit characters,
+// like the prec option. It reports false if the number has no decimals or its integer part does not fit.
+func (m *Marshaler) roundFraction(lowerBound, limit int) bool {
+	b := m.b[lowerBound:]
+	if bytes.IndexAny(b, "eE") >= 0 {
+		return false
+	}
+
+	// the signs are copied, b is overwritten by the rounded number
+	var lead, trail []byte
+	if hasSign(b) {
+		lead, b = []byte{b[0]}, b[1:]
+	}
+	if n := len(b); n > 0 && (b[n-1] == '-' || b[n-1] == '+') {
+		trail, b = []byte{b[n-1]}, b[:n-1]
+	}
+	dot := bytes.IndexByte(b, '.')
+	if dot < 0 {
+		return false
+	}
+	x, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return false
+	}
+
+	limit -= len(lead) + len(trail)
+	prec := limit - dot - 1
+	if prec < 0 {
+		prec = 0
+	}
+	// rounding up may add an integer digit, 9.96 in 3 columns is 10
+	var buf [32]byte
+	for ; prec >= 0; prec-- {
+		r := strconv.AppendFloat(append(buf[:0], lead...), x, 'f', prec, 64)
+		if len(r)-len(lead) <= limit {
+			m.b = append(append(m.b[:lowerBound], r...), trail...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package fixedwidth
+
+import (
+	"errors"
+	"testing"
+)
+
+type overflowRecord struct {
+	Name    string  `fixed:"5"`
+	Account string  `fixed:"6,overflow=left"`
+	Amount  int     `fixed:"4,right"`
+	Rate    float64 `fixed:"4"`
+	Code    string  `fixed:"3,overflow=mark"`
+}
+
+func TestMarshal_Overflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow Overflow
+		marker   rune
+		v        overflowRecord
+		want     string
+	}{
+		{
+			name: "fits",
+			v:    overflowRecord{Name: "Huy", Account: "123", Amount: -12, Rate: 1.5, Code: "AB"},
+			want: "Huy  123    -121.50AB ",
+		},
+		{
+			name: "default",
+			v:    overflowRecord{Name: "Alexander", Account: "9876543210", Amount: 12, Rate: 12.345, Code: "ABCD"},
+			want: "Alexa543210  1212.3***",
+		},
+		{
+			name: "default rounded",
+			v:    overflowRecord{Amount: 1, Rate: 9.996},
+			want: "              110.0   ",
+		},
+		{
+			name: "default rounded negative",
+			v:    overflowRecord{Amount: 1, Rate: -1.96},
+			want: "              1-2.0   ",
+		},
+		{
+			name:     "truncate",
+			overflow: OverflowTruncate,
+			v:        overflowRecord{Amount: 12345, Rate: 123456},
+			want:     "           12341234   ",
+		},
+		{
+			name:     "left",
+			overflow: OverflowTruncateLeft,
+			v:        overflowRecord{Name: "Alexander", Amount: -12345, Rate: 1},
+			want:     "ander      -3451.00   ",
+		},
+		{
+			name:     "mark",
+			overflow: OverflowMark,
+			marker:   '#',
+			v:        overflowRecord{Name: "Alexander", Amount: 12345, Code: "ABCD"},
+			want:     "#####      ####0.00###",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.Overflow = tt.overflow
+			m.OverflowMarker = tt.marker
+			got, err := m.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal_OverflowError(t *testing.T) {
+	tests := []struct {
+		name      string
+		overflow  Overflow
+		v         []overflowRecord
+		wantLine  int
+		wantField string
+		wantValue string
+	}{
+		{
+			name:      "number",
+			v:         []overflowRecord{{Amount: 1}, {Amount: 12345}},
+			wantLine:  2,
+			wantField: "Amount",
+			wantValue: "12345",
+		},
+		{
+			name:      "float",
+			v:         []overflowRecord{{Rate: 12345}},
+			wantLine:  1,
+			wantField: "Rate",
+			wantValue: "12345.00",
+		},
+		{
+			name:      "float rounded up",
+			v:         []overflowRecord{{Rate: 9999.6}},
+			wantLine:  1,
+			wantField: "Rate",
+			wantValue: "9999.60",
+		},
+		{
+			name:      "text",
+			overflow:  OverflowError,
+			v:         []overflowRecord{{Name: "Alexander"}},
+			wantLine:  1,
+			wantField: "Name",
+			wantValue: "Alexander",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.Overflow = tt.overflow
+			_, err := m.Marshal(tt.v)
+
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || !errors.Is(err, ErrOverflow) {
+				t.Fatalf("Marshal() got error %v, want an overflow FieldError", err)
+			}
+			if fieldErr.Line != tt.wantLine || fieldErr.Field != tt.wantField || fieldErr.Value != tt.wantValue {
+				t.Errorf("Marshal() got = %+v, want line %d, field %s, value %q", fieldErr, tt.wantLine, tt.wantField, tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package fixedwidth
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// plans caches a *planEntry for every struct type, keyed by reflect.Type.
+// Plans never change once they are built, so they are safe to share between goroutines.
+var plans sync.Map
+
+type planEntry struct {
+	plan *typePlan
+	err  error
+}
+
+// typePlan is the compiled layout of a struct type.
+// It is built once per type and shared by the Marshaler and the Unmarshaler,
+// so the fixed tags are parsed only once instead of on every record.
+type typePlan struct {
+	// fields are the leaf fields of the struct ordered by offset.
+	// Nested structs are flattened into their leaf fields.
+	fields []*fieldPlan
+
+	// width is the total width of a record
+	width int
+
+	// controls is true if a field is a control total, see controlTotal
+	controls bool
+
+	// binary is true if a number field is written in bytes instead of text, see binaryCodec
+	binary bool
+}
+
+// fieldPlan describes where a leaf field is located in a record and how it is converted
+type fieldPlan struct {
+	// name is the path of the field, e.g. Customer.Zip
+	name string
+
+	// index is the index path of the field starting from the record struct
+	index []int
+
+	// offset is the position of the first rune of the field in the record
+	offset int
+
+	// span is the number of runes of the field kept in the record.
+	// It is less than width when the field is truncated by the fixed tag of an enclosing struct.
+	span int
+
+	// numeric is true for integer and floating-point fields
+	numeric bool
+
+	tagOptions
+	codec
+}
+
+// alignFor returns the alignment of the field when it is filled with pad.
+// Numbers filled with zeros are right-aligned unless the tag sets the alignment.
+func (f *fieldPlan) alignFor(pad rune) alignment {
+	if f.align != alignDefault {
+		return f.align
+	}
+	if f.numeric && pad == '0' {
+		return alignRight
+	}
+	return alignLeft
+}
+
+// planFor returns the cached plan of struct type t, building it on first use
+func planFor(t reflect.Type) (*typePlan, error) {
+	if e, ok := plans.Load(t); ok {
+		return e.(*planEntry).plan, e.(*planEntry).err
+	}
+
+	p, err := buildPlan(t)
+	e, _ := plans.LoadOrStore(t, &planEntry{plan: p, err: err})
+	return e.(*planEntry).plan, e.(*planEntry).err
+}
+
+func buildPlan(t reflect.Type) (*typePlan, error) {
+	b := planBuilder{}
+	width, err := b.addStruct(t, nil, "", 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(b.fields, func(i, j int) bool {
+		return b.fields[i].offset < b.fields[j].offset
+	})
+	for i := 1; i < len(b.fields); i++ {
+		prev, f := b.fields[i-1], b.fields[i]
+		if f.offset < prev.offset+prev.span {
+			return nil, fmt.Errorf("field %s overlaps field %s", f.name, prev.name)
+		}
+	}
+
+	p := &typePlan{fields: b.fields, width: width}
+	for _, f := range p.fields {
+		if f.control != controlNone {
+			p.controls = true
+		}
+		if f.usage != usageDisplay {
+			p.binary = true
+		}
+	}
+	return p, nil
+}
+
+type planBuilder struct {
+	tag
+	fields []*fieldPlan
+
+	// visiting holds the struct types being added, it prevents infinite recursion
+	visiting map[reflect.Type]bool
+}
+
+// addStruct appends the leaf fields of struct type t located at offset,
+// fields (or parts of them) at or beyond end are dropped; end < 0 means no limit.
+// It returns the width of the struct.
+//
+// A field follows the previous declared field unless its tag defines the columns,
+// columns are relative to the beginning of the struct.
+func (b *planBuilder) addStruct(t reflect.Type, index []int, prefix string, offset, end int) (int, error) {
+	if b.visiting[t] {
+		return 0, fmt.Errorf("recursive struct type %s", t)
+	}
+	if b.visiting == nil {
+		b.visiting = make(map[reflect.Type]bool)
+	}
+	b.visiting[t] = true
+	defer delete(b.visiting, t)
+
+	// cursor is the end of the previous field, width is the end of the last column
+	cursor, width := 0, 0
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		opts, err := b.parse(structField)
+		if err != nil {
+			return 0, fmt.Errorf("invalid fixed tag of field %s: %v", structField.Name, err)
+		}
+		limit := opts.width
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+		name := structField.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if opts.start > 0 {
+			cursor = opts.start - 1
+		}
+		fieldOffset := offset + cursor
+
+		if structField.PkgPath != "" && !structField.Anonymous {
+			// unexported fields cannot be set, their columns are kept blank
+			cursor += limit
+			if cursor > width {
+				width = cursor
+			}
+			continue
+		}
+
+		if isStructOrStructPointer(structField.Type) && !hasCustomCodec(structField.Type) {
+			fieldEnd := end
+			if limit > 0 && (end < 0 || fieldOffset+limit < end) {
+				fieldEnd = fieldOffset + limit
+			}
+
+			structType := structField.Type
+			if structType.Kind() == reflect.Ptr {
+				structType = structType.Elem()
+				if limit == 0 && b.visiting[structType] {
+					// an untagged pointer to an enclosing struct, such as the next node of a list,
+					// is not a part of the record
+					continue
+				}
+			}
+			w, err := b.addStruct(structType, fieldIndex, name, fieldOffset, fieldEnd)
+			if err != nil {
+				return 0, err
+			}
+
+			if limit > 0 {
+				w = limit
+			}
+			cursor += w
+			if cursor > width {
+				width = cursor
+			}
+			continue
+		}
+
+		// fields without fixed tag are not a part of the record
+		if limit == 0 {
+			continue
+		}
+		cursor += limit
+		if cursor > width {
+			width = cursor
+		}
+
+		span := limit
+		if end >= 0 && fieldOffset+span > end {
+			span = end - fieldOffset
+		}
+		if span <= 0 {
+			continue
+		}
+
+		c, err := codecFor(structField.Type, opts)
+		if err == nil {
+			err = opts.check(structField.Type)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("field %s: %v", name, err)
+		}
+		b.fields = append(b.fields, &fieldPlan{
+			name:       name,
+			index:      fieldIndex,
+			offset:     fieldOffset,
+			span:       span,
+			numeric:    isNumeric(structField.Type),
+			tagOptions: opts,
+			codec:      c,
+		})
+	}
+
+	return width, nil
+}
+
+func isFloat(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+func isInteger(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isNumeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// fieldByIndex returns the nested field of v by index,
+// an invalid value is returned if the path goes through a nil pointer.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldByIndexAlloc returns the nested field of v by index,
+// nil pointers on the path are allocated.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, errors.New("cannot set embedded pointer to unexported struct")
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
@@ -0,0 +1,264 @@
+package fixedwidth
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type planField struct {
+	name   string
+	offset int
+	width  int
+	span   int
+}
+
+func Test_planFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		v         interface{}
+		wantWidth int
+		want      []planField
+	}{
+		{
+			name:      "flat struct",
+			v:         person{},
+			wantWidth: 32,
+			want: []planField{
+				{"FirstName", 0, 10, 10},
+				{"LastName", 10, 10, 10},
+				{"Age", 20, 4, 4},
+				{"Job", 24, 8, 8},
+			},
+		},
+		{
+			name:      "nested struct with tag",
+			v:         nestedStructWithTag{},
+			wantWidth: 13,
+			want: []planField{
+				{"Cat.Name", 0, 10, 10},
+				{"Cat.Gender", 10, 6, 3},
+			},
+		},
+		{
+			name:      "embedded struct with tag",
+			v:         embeddedStructWithTag{},
+			wantWidth: 18,
+			want: []planField{
+				{"Number", 0, 3, 3},
+				{"person.FirstName", 3, 10, 10},
+				{"person.LastName", 13, 10, 5},
+			},
+		},
+		{
+			name:      "columns",
+			v:         columnStruct{},
+			wantWidth: 20,
+			want: []planField{
+				{"Name", 0, 8, 8},
+				{"Code", 9, 2, 2},
+				{"Note", 11, 3, 3},
+				{"Amount", 14, 6, 6},
+			},
+		},
+		{
+			name: "nested struct with columns",
+			v: struct {
+				ID  string `fixed:"2"`
+				Cat cat    `fixed:"5-15"`
+			}{},
+			wantWidth: 15,
+			want: []planField{
+				{"ID", 0, 2, 2},
+				{"Cat.Name", 4, 10, 10},
+				{"Cat.Gender", 14, 6, 1},
+			},
+		},
+		{
+			name:      "nested struct pointer",
+			v:         struct{ F8 *cat }{},
+			wantWidth: 16,
+			want: []planField{
+				{"F8.Name", 0, 10, 10},
+				{"F8.Gender", 10, 6, 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := planFor(reflect.TypeOf(tt.v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.width != tt.wantWidth {
+				t.Errorf("width got = %d, want %d", p.width, tt.wantWidth)
+			}
+
+			var got []planField
+			for _, f := range p.fields {
+				got = append(got, planField{f.name, f.offset, f.width, f.span})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fields got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type recursiveStruct struct {
+	Name string           `fixed:"3"`
+	Next *recursiveStruct `fixed:"6"`
+}
+
+func Test_planFor_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "invalid tag", v: struct {
+			Name string `fixed:"abc"`
+		}{}},
+		{name: "unsupported type", v: struct {
+			Names []string `fixed:"10"`
+		}{}},
+		{name: "implied decimals of a string", v: struct {
+			Name string `fixed:"5,implied=2"`
+		}{}},
+		{name: "precision of an integer", v: struct {
+			Amount int `fixed:"5,prec=2"`
+		}{}},
+		{name: "implied decimals with format", v: struct {
+			Amount float64 `fixed:"5,implied=2,fmt=e"`
+		}{}},
+		{name: "sign of a string", v: struct {
+			Name string `fixed:"5,plus"`
+		}{}},
+		{name: "separate sign without digits", v: struct {
+			Amount int `fixed:"1,sign=leading-separate"`
+		}{}},
+		{name: "zoned with sign", v: struct {
+			Amount int `fixed:"5,zoned,plus"`
+		}{}},
+		{name: "comp of a string", v: struct {
+			Name string `fixed:"5,comp"`
+		}{}},
+		{name: "comp wider than 8 bytes", v: struct {
+			Amount int64 `fixed:"9,comp"`
+		}{}},
+		{name: "unsigned text", v: struct {
+			Amount int `fixed:"5,unsigned"`
+		}{}},
+		{name: "sum of a float", v: struct {
+			Total float64 `fixed:"9,sum=Amount"`
+		}{}},
+		{name: "recursive struct", v: recursiveStruct{}},
+		{name: "overlapping columns", v: struct {
+			Name string `fixed:"1-8"`
+			Code string `fixed:"8-9"`
+		}{}},
+		{name: "overlapping sequential field", v: struct {
+			Code string `fixed:"3-4"`
+			Name string `fixed:"1-2"`
+			Note string `fixed:"1"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := planFor(reflect.TypeOf(tt.v)); err == nil {
+				t.Error("planFor() expected error")
+			}
+			if _, err := Marshal(tt.v); err == nil {
+				t.Error("Marshal() expected error")
+			}
+		})
+	}
+}
+
+func Test_planFor_Concurrent(t *testing.T) {
+	type concurrentStruct struct {
+		A string `fixed:"2"`
+		B int    `fixed:"3"`
+	}
+
+	var wg sync.WaitGroup
+	plans := make([]*typePlan, 8)
+	for i := range plans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			plans[i], _ = planFor(reflect.TypeOf(concurrentStruct{}))
+		}(i)
+	}
+	wg.Wait()
+
+	for _, p := range plans {
+		if p != plans[0] {
+			t.Fatal("planFor() returned different plans for the same type")
+		}
+	}
+}
+
+func TestMarshal_NilNestedStruct(t *testing.T) {
+	v := struct {
+		F8  *cat
+		Age int `fixed:"3"`
+	}{Age: 7}
+
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "                7  "; string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+}
+
+func TestMarshal_UnexportedField(t *testing.T) {
+	type record struct {
+		A string `fixed:"3"`
+		b string `fixed:"3"`
+		C string `fixed:"3"`
+	}
+
+	got, err := Marshal(record{A: "a", b: "b", C: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the columns of the unexported field are kept blank
+	if want := "a     c  "; string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+
+	var r record
+	if err := Unmarshal([]byte("a  b  c  "), &r); err != nil {
+		t.Fatal(err)
+	}
+	if want := (record{A: "a", C: "c"}); r != want {
+		t.Errorf("Unmarshal() got = %+v, want %+v", r, want)
+	}
+}
+
+func TestMarshal_SelfReferencingPointer(t *testing.T) {
+	type node struct {
+		V    int `fixed:"2"`
+		Next *node
+	}
+
+	got, err := Marshal(node{V: 1, Next: &node{V: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 "; string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+
+	var n node
+	if err := Unmarshal([]byte("1 "), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.V != 1 || n.Next != nil {
+		t.Errorf("Unmarshal() got = %+v, want {V:1 Next:<nil>}", n)
+	}
+}
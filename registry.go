@@ -0,0 +1,139 @@
+package fixedwidth
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"unicode/utf8"
+)
+
+// Registry holds the record types of a file mixing several kinds of records,
+// such as header, detail and trailer records. The type of a record is given
+// by its code in the discriminator columns.
+//
+// Types are registered before the Registry is used, it is then safe for concurrent use.
+type Registry struct {
+	// start and end are the discriminator columns, 1-based and inclusive
+	start, end int
+
+	types map[string]reflect.Type
+	codes map[reflect.Type]string
+
+	// docs caches the plans of the document types, see documentPlan
+	docs sync.Map
+}
+
+// NewRegistry returns an empty Registry reading the code of records from columns start to end,
+// 1-based and inclusive.
+func NewRegistry(start, end int) *Registry {
+	return &Registry{
+		start: start,
+		end:   end,
+		types: make(map[string]reflect.Type),
+		codes: make(map[reflect.Type]string),
+	}
+}
+
+// Register adds the record type of v, a struct or a pointer to a struct, for the given code.
+// Records with this code are decoded as values of the same type as v.
+func (r *Registry) Register(code string, v interface{}) error {
+	if r.start < 1 || r.end < r.start {
+		return fmt.Errorf("invalid discriminator columns %d-%d", r.start, r.end)
+	}
+	if utf8.RuneCountInString(code) != r.end-r.start+1 {
+		return fmt.Errorf("code %q does not fill the discriminator columns %d-%d", code, r.start, r.end)
+	}
+
+	t := reflect.TypeOf(v)
+	if t == nil || !isStructOrStructPointer(t) {
+		return fmt.Errorf("record type of code %q must be a struct or a pointer to a struct", code)
+	}
+	if _, err := planFor(structType(t)); err != nil {
+		return err
+	}
+
+	if _, ok := r.types[code]; ok {
+		return fmt.Errorf("code %q is already registered", code)
+	}
+	if c, ok := r.codes[structType(t)]; ok {
+		return fmt.Errorf("type %s is already registered with code %q", t, c)
+	}
+	r.types[code] = t
+	r.codes[structType(t)] = code
+	return nil
+}
+
+// structType returns the struct type of t, a struct or a pointer to a struct
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// code returns the discriminator of record data, which width is counted in mode
+func (r *Registry) code(data []byte, mode WidthMode) []byte {
+	start := mode.upperBound(0, r.start-1, data)
+	return data[start:mode.upperBound(start, r.end-r.start+1, data)]
+}
+
+// unmarshalRecord decodes a record into a new value of its registered type, stored in modelValue
+func (d *decodeState) unmarshalRecord(data []byte, modelValue reflect.Value) error {
+	code := d.recordCode(data)
+	t, ok := d.Registry.types[string(code)]
+	if !ok {
+		return &RecordError{Line: d.line, Err: fmt.Errorf("unknown record type %q", code)}
+	}
+	if !t.AssignableTo(modelValue.Type()) {
+		return &RecordError{Line: d.line, Err: fmt.Errorf("record type %s is not assignable to %s", t, modelValue.Type())}
+	}
+
+	newValue := reflect.New(t).Elem()
+	if err := d.unmarshal(data, newValue); err != nil {
+		return err
+	}
+	modelValue.Set(newValue)
+	return nil
+}
+
+// writeCode writes the code of the record type t in the discriminator columns of the record starting at start
+func (m *Marshaler) writeCode(t reflect.Type, start int) error {
+	code, ok := m.Registry.codes[t]
+	if !ok {
+		return fmt.Errorf("type %s is not registered", t)
+	}
+
+	mode := m.widthMode()
+	record := m.b[start:]
+	if w := mode.width(record); w < m.Registry.end {
+		m.appendPadding(m.Registry.end-w, m.padRune(0))
+		record = m.b[start:]
+	}
+
+	lower := mode.upperBound(0, m.Registry.start-1, record)
+	upper := mode.upperBound(lower, m.Registry.end-m.Registry.start+1, record)
+	m.b = append(m.b[:start+lower], append([]byte(code), record[upper:]...)...)
+	return nil
+}
+
+// UnmarshalEach decodes the records of data one by one and calls fn with each of them,
+// following the Registry of the Unmarshaler: the values are of the registered types.
+// It stops at the first error returned by fn.
+func (m Unmarshaler) UnmarshalEach(data []byte, fn func(v interface{}) error) error {
+	if m.Registry == nil {
+		return errors.New("UnmarshalEach requires a Registry")
+	}
+
+	d := &decodeState{Unmarshaler: m}
+	if m.Registry.controlled() {
+		d.scopes.push()
+	}
+	return d.eachRecord(data, nil, func(record []byte) error {
+		var v interface{}
+		if err := d.unmarshalRecord(record, reflect.ValueOf(&v).Elem()); err != nil {
+			return err
+		}
+		return fn(v)
+	})
+}
@@ -0,0 +1,157 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type batchHeader struct {
+	Type string `fixed:"2"`
+	Bank string `fixed:"8"`
+}
+
+type batchDetail struct {
+	Type    string `fixed:"2"`
+	Account string `fixed:"6"`
+	Amount  int    `fixed:"5,right,pad=0"`
+}
+
+type batchTrailer struct {
+	Count int `fixed:"3-5,right,pad=0"`
+}
+
+var batchTypes = map[string]interface{}{"01": batchHeader{}, "05": &batchDetail{}, "99": batchTrailer{}}
+
+// newRegistry returns a Registry of the codes in columns start to end, registering every value of types under its code
+func newRegistry(t *testing.T, start, end int, types map[string]interface{}) *Registry {
+	r := NewRegistry(start, end)
+	for code, v := range types {
+		if err := r.Register(code, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+var batchRecords = []interface{}{
+	batchHeader{Type: "01", Bank: "ACME"},
+	&batchDetail{Type: "05", Account: "A1", Amount: 150},
+	&batchDetail{Type: "05", Account: "B2", Amount: 25},
+	batchTrailer{Count: 2},
+}
+
+const batchData = "01ACME    \n" + "05A1    00150\n" + "05B2    00025\n" + "99002"
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry(t, 1, 2, batchTypes)
+
+	m := NewMarshaler()
+	m.Registry = r
+	got, err := m.Marshal(batchRecords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != batchData {
+		t.Errorf("Marshal() got = %q, want %q", got, batchData)
+	}
+
+	u := NewUnmarshaler()
+	u.Registry = r
+	var records []interface{}
+	if err := u.Unmarshal([]byte(batchData), &records); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, batchRecords) {
+		t.Errorf("Unmarshal() got = %+v, want %+v", records, batchRecords)
+	}
+
+	var each []interface{}
+	err = u.UnmarshalEach([]byte(batchData), func(v interface{}) error {
+		each = append(each, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(each, batchRecords) {
+		t.Errorf("UnmarshalEach() got = %+v, want %+v", each, batchRecords)
+	}
+
+	d := NewDecoder(bytes.NewBufferString(batchData))
+	d.Registry = r
+	for _, want := range batchRecords {
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("Decode() got = %+v, want %+v", v, want)
+		}
+	}
+	var v interface{}
+	if err := d.Decode(&v); err != io.EOF {
+		t.Errorf("Decode() got error %v, want io.EOF", err)
+	}
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	r := newRegistry(t, 1, 2, batchTypes)
+
+	tests := []struct {
+		name string
+		code string
+		v    interface{}
+	}{
+		{name: "short code", code: "1", v: batchHeader{}},
+		{name: "duplicate code", code: "01", v: struct{}{}},
+		{name: "duplicate type", code: "02", v: &batchHeader{}},
+		{name: "not a struct", code: "03", v: "header"},
+		{name: "invalid tag", code: "04", v: struct {
+			Name string `fixed:"x"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Register(tt.code, tt.v); err == nil {
+				t.Error("Register() expected error")
+			}
+		})
+	}
+
+	m := NewMarshaler()
+	m.Registry = r
+	if _, err := m.Marshal([]interface{}{batchHeader{}, person{}}); err == nil {
+		t.Error("Marshal() expected error for an unregistered type")
+	}
+
+	u := NewUnmarshaler()
+	u.Registry = r
+	u.ContinueOnError = true
+	var records []interface{}
+	err := u.Unmarshal([]byte("01ACME\n07XYZ\n05A1    0x150\n99001"), &records)
+
+	var errs ErrorList
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Unmarshal() got error %v, want 2 errors", err)
+	}
+	var recordErr *RecordError
+	if !errors.As(errs[0], &recordErr) || recordErr.Line != 2 {
+		t.Errorf("Unmarshal() got error %v, want an unknown record type on line 2", errs[0])
+	}
+	if len(records) != 2 {
+		t.Errorf("Unmarshal() got %d records, want 2", len(records))
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = u.UnmarshalEach([]byte(batchData), func(v interface{}) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("UnmarshalEach() got error %v after %d calls, want stop after 1 call", err, calls)
+	}
+}
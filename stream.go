@@ -0,0 +1,240 @@
+package fixedwidth
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"unicode/utf8"
+)
+
+// Encoder writes fixed-width records to an output stream
+type Encoder struct {
+	*Marshaler
+	w *bufio.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+// Records are buffered, call Flush after the last Encode.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		Marshaler: NewMarshaler(),
+		w:         bufio.NewWriter(w),
+	}
+}
+
+// Encode writes the fixed-width encoding of v, every record is followed by the Terminator.
+//
+// v should be a struct or a slice of struct, or a pointer to them.
+// If v is a slice, every element is written as its own record.
+// If a record cannot be encoded, such as a value which is not a struct, nothing is written.
+func (e *Encoder) Encode(v interface{}) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if e.Registry != nil && len(e.scopes) == 0 && e.Registry.controlled() {
+		// the control totals cover every record written by the Encoder
+		e.scopes.push()
+	}
+	records := e.scopes.size()
+
+	e.reset()
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	var err error
+	if rv.Kind() != reflect.Slice {
+		err = e.encodeRecord(rv)
+	} else {
+		for i := 0; i < rv.Len() && err == nil; i++ {
+			err = e.encodeRecord(rv.Index(i))
+		}
+	}
+	if err != nil {
+		// the records which are not written are not controlled either
+		e.scopes.drop(records)
+		return err
+	}
+
+	_, err = e.w.Write(e.b)
+	return err
+}
+
+// encodeRecord marshals a single record, followed by the Terminator,
+// into the underlying slice of bytes of the Marshaler
+func (e *Encoder) encodeRecord(v reflect.Value) error {
+	record := v
+	for (record.Kind() == reflect.Ptr || record.Kind() == reflect.Interface) && !record.IsNil() {
+		record = record.Elem()
+	}
+	if record.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot encode %s, a record must be a struct", describe(v))
+	}
+
+	if err := e.marshal(v); err != nil {
+		return err
+	}
+	e.b = append(e.b, e.Terminator...)
+	return nil
+}
+
+// describe returns the type of v for error messages
+func describe(v reflect.Value) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "nil " + v.Type().String()
+	}
+	return v.Type().String()
+}
+
+// Flush writes any buffered records to the underlying io.Writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Decoder reads fixed-width records from an input stream
+type Decoder struct {
+	Unmarshaler
+	r *bufio.Reader
+
+	// buf holds the current record, it is reused for every record
+	buf []byte
+
+	// line is the number of the last record read, starting from 1
+	line int
+
+	// state is reused by every Decode call
+	state decodeState
+
+	// err stops the decoding of records without terminator once the next record cannot be located
+	err error
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+// Records are separated by the Terminator, a new line character (\n) by default.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		Unmarshaler: NewUnmarshaler(),
+		r:           bufio.NewReader(r),
+	}
+}
+
+// More reports whether there is another record in the input stream.
+func (d *Decoder) More() bool {
+	d.skipBlankLines()
+	_, err := d.r.Peek(1)
+	return err == nil && d.err == nil
+}
+
+// Line returns the line number of the last record read by Decode.
+func (d *Decoder) Line() int {
+	return d.line
+}
+
+// Decode reads the next record from its input and stores it in the value pointed to by v.
+// v is required to be a pointer, usually to a struct.
+//
+// If the record cannot be decoded, the error is returned
+// and the next call of Decode continues with the following record.
+// At the end of the input stream, Decode returns io.EOF.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("the model must be a pointer")
+	}
+
+	record, err := d.readRecord(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	d.line++
+
+	d.state.Unmarshaler = d.Unmarshaler
+	d.state.line = d.line
+	if d.Registry != nil && len(d.state.scopes) == 0 && d.Registry.controlled() {
+		// the control totals cover every record read by the Decoder
+		d.state.scopes.push()
+	}
+	return d.state.unmarshal(record, rv.Elem())
+}
+
+// readRecord returns the next record, decoded into a value of type t, without its terminator.
+// The returned slice is only valid until the next call of readRecord.
+func (d *Decoder) readRecord(t reflect.Type) ([]byte, error) {
+	d.buf = d.buf[:0]
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.skipBlankLines()
+	if len(d.Terminator) == 0 {
+		return d.readFixedLength(t)
+	}
+
+	last := d.Terminator[len(d.Terminator)-1]
+	for {
+		b, err := d.r.ReadSlice(last)
+		d.buf = append(d.buf, b...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(d.buf) > 0 {
+			// the last record does not have a terminator
+			return d.trimCR(d.buf), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasSuffix(d.buf, d.Terminator) {
+			return d.trimCR(d.buf[:len(d.buf)-len(d.Terminator)]), nil
+		}
+	}
+}
+
+// trimCR removes the carriage return of a record ending with \r\n, when the Terminator is \n
+func (d *Decoder) trimCR(record []byte) []byte {
+	if d.crlf() {
+		return bytes.TrimSuffix(record, []byte("\r"))
+	}
+	return record
+}
+
+// readFixedLength returns the next record, which takes the width of type t
+// or of the type registered for its code. A last record shorter than its width is a RecordError.
+func (d *Decoder) readFixedLength(t reflect.Type) ([]byte, error) {
+	mode := d.byteMode()
+
+	var head []byte
+	if d.Registry != nil {
+		// the code is within the first columns, each of them takes up to utf8.UTFMax bytes
+		n := d.Registry.end
+		if mode != Bytes {
+			n *= utf8.UTFMax
+		}
+		head, _ = d.r.Peek(n)
+	}
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	d.state.Unmarshaler = d.Unmarshaler
+	d.state.line = d.line + 1
+	p, err := d.state.recordPlan(head, t)
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+
+	err = d.readFixedRecord(p, mode)
+	if err == io.ErrUnexpectedEOF {
+		err = d.state.shortRecord()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.buf, nil
+}
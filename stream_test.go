@@ -0,0 +1,282 @@
+package fixedwidth
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	people := []person{
+		{FirstName: "Alexander", LastName: "Goodword", Age: 40, Job: "Software Engineer"},
+		{FirstName: "Frank", LastName: "Lampard", Age: 41, Job: "Coach"},
+	}
+
+	tests := []struct {
+		name       string
+		values     []interface{}
+		terminator []byte
+		want       string
+	}{
+		{
+			name:   "single record",
+			values: []interface{}{people[0]},
+			want:   "Alexander Goodword  40  Software\n",
+		},
+		{
+			name:   "records one by one",
+			values: []interface{}{people[0], &people[1]},
+			want:   "Alexander Goodword  40  Software\nFrank     Lampard   41  Coach   \n",
+		},
+		{
+			name:   "slice of records",
+			values: []interface{}{people},
+			want:   "Alexander Goodword  40  Software\nFrank     Lampard   41  Coach   \n",
+		},
+		{
+			name:   "pointer to a slice of records",
+			values: []interface{}{&people},
+			want:   "Alexander Goodword  40  Software\nFrank     Lampard   41  Coach   \n",
+		},
+		{
+			name:       "custom terminator",
+			values:     []interface{}{people},
+			terminator: []byte("\r\n"),
+			want:       "Alexander Goodword  40  Software\r\nFrank     Lampard   41  Coach   \r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			if tt.terminator != nil {
+				e.Terminator = tt.terminator
+			}
+			for _, v := range tt.values {
+				if err := e.Encode(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Encode() got = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoder_Encode_Errors(t *testing.T) {
+	var nilPerson *person
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "nil", v: nil},
+		{name: "integer", v: 42},
+		{name: "nil pointer", v: nilPerson},
+		{name: "slice of integers", v: []int{1, 2}},
+		{name: "nil element", v: []interface{}{person{}, nil}},
+		{name: "nil pointer element", v: []*person{{FirstName: "y"}, nil}},
+		{name: "overflowing element", v: []person{{Age: 1}, {Age: 12345}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewEncoder(&buf)
+			if err := e.Encode(tt.v); err == nil {
+				t.Error("Encode() expected error")
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() > 0 {
+				t.Errorf("Encode() wrote %q, want nothing", buf.String())
+			}
+		})
+	}
+}
+
+func TestEncoder_WriterError(t *testing.T) {
+	wantErr := errors.New("disk full")
+	e := NewEncoder(errWriter{err: wantErr})
+
+	// the first records fit into the buffer, the error shows up on Flush
+	err := e.Encode(person{FirstName: "Huy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != wantErr {
+		t.Errorf("Flush() error = %v, want %v", err, wantErr)
+	}
+
+	// once the writer failed, every following Encode reports the error
+	if err := e.Encode(person{FirstName: "Huy"}); err != wantErr {
+		t.Errorf("Encode() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []person
+	}{
+		{
+			name: "empty input",
+			data: "",
+			want: nil,
+		},
+		{
+			name: "single record",
+			data: "Huy       Đặng      25  Engineer",
+			want: []person{
+				{FirstName: "Huy", LastName: "Đặng", Age: 25, Job: "Engineer"},
+			},
+		},
+		{
+			name: "multiple records with new line at the end",
+			data: "Huy       Dang      25  Engineer\nDidier    Drogba    41  Retired \n",
+			want: []person{
+				{FirstName: "Huy", LastName: "Dang", Age: 25, Job: "Engineer"},
+				{FirstName: "Didier", LastName: "Drogba", Age: 41, Job: "Retired"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.data))
+			var got []person
+			for d.More() {
+				var p person
+				if err := d.Decode(&p); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, p)
+			}
+
+			var p person
+			if err := d.Decode(&p); err != io.EOF {
+				t.Errorf("Decode() error = %v, want %v", err, io.EOF)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoder_LongRecord(t *testing.T) {
+	// a record longer than the buffer of the underlying reader
+	data := strings.Repeat("a", 10000) + "\n" + strings.Repeat("b", 10)
+	d := NewDecoder(strings.NewReader(data))
+
+	var c cat
+	if err := d.Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "aaaaaaaaaa" || c.Gender != "aaaaaa" {
+		t.Errorf("Decode() got = %+v", c)
+	}
+	if err := d.Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "bbbbbbbbbb" {
+		t.Errorf("Decode() got = %+v", c)
+	}
+}
+
+func TestDecoder_Error(t *testing.T) {
+	data := "Huy       Dang      25  Engineer\nDidier    Drogba    4x  Retired "
+	d := NewDecoder(strings.NewReader(data))
+
+	var p person
+	if err := d.Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	err := d.Decode(&p)
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Line != 2 || fieldErr.Field != "Age" {
+		t.Errorf("Decode() error = %v, want error of field Age on line 2", err)
+	}
+	if d.Line() != 2 {
+		t.Errorf("Line() got = %d, want 2", d.Line())
+	}
+
+	if err := d.Decode(p); err == nil {
+		t.Error("Decode() expected error for non-pointer model")
+	}
+}
+
+func TestStream_ControlTotals(t *testing.T) {
+	r := newRegistry(t, 1, 1, docTypes)
+	data := "5001\n" + "6001200150\n" + "6009900025\n" + "800211000175\n" + "90010040000175\n"
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.Registry = r
+	// a failed call is not counted
+	if err := e.Encode([]interface{}{docBatchHeader{Batch: 1}, docEntry{Amount: 123456}}); err == nil {
+		t.Fatal("Encode() expected error")
+	}
+	values := []interface{}{
+		docBatchHeader{Batch: 1},
+		[]docEntry{{Routing: "0012", Amount: 150}, {Routing: "0099", Amount: 25}},
+		docBatchControl{},
+		&docFileControl{},
+	}
+	for _, v := range values {
+		if err := e.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != data {
+		t.Errorf("Encode() got = %q, want %q", buf.String(), data)
+	}
+
+	// the decoded value is reused for every entry
+	d := NewDecoder(strings.NewReader(data))
+	d.Registry = r
+	var (
+		header  docBatchHeader
+		entry   docEntry
+		control docBatchControl
+		trailer docFileControl
+	)
+	for _, v := range []interface{}{&header, &entry, &entry, &control, &trailer} {
+		if err := d.Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d = NewDecoder(strings.NewReader("5001\n6001200150\n800112000151\n"))
+	d.Registry = r
+	var err error
+	for err == nil {
+		var v interface{}
+		err = d.Decode(&v)
+	}
+	want := ControlTotalError{Line: 3, Field: "Amount", Expected: 150, Actual: 151}
+	var controlErr *ControlTotalError
+	if !errors.As(err, &controlErr) || *controlErr != want {
+		t.Errorf("Decode() got error %v, want %v", err, &want)
+	}
+}
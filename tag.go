@@ -1,25 +1,273 @@
 package fixedwidth
 
 import (
+	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 const tagName = "fixed"
 
 type tag struct{}
 
-// getLimitFixedTag get the tag `fixed` of a struct field then convert to integer
-// if fixed tag is valid true will be returned; otherwise, false will be returned
-func (tag) getLimitFixedTag(field reflect.StructField) (int, bool) {
+// alignment is the position of a value inside its field
+type alignment int
+
+const (
+	// alignDefault is left, except for numbers filled with zeros which are right-aligned
+	alignDefault alignment = iota
+	alignLeft
+	alignRight
+	alignCenter
+)
+
+// alignments maps the alignment options of the fixed tag
+var alignments = map[string]alignment{
+	"left":   alignLeft,
+	"right":  alignRight,
+	"center": alignCenter,
+}
+
+// tagOptions holds the parsed `fixed` tag of a struct field.
+//
+// The tag starts with the width of the field, or its first and last columns (1-based, inclusive),
+// followed by comma separated options, e.g. `fixed:"10,right,pad=0"` or `fixed:"15-24"`.
+type tagOptions struct {
+	width int
+
+	// start is the first column of the field, zero if the field follows the previous one
+	start int
+
+	align alignment
+
+	// pad is the character filling the field, zero means the default of the Marshaler or Unmarshaler
+	pad rune
+
+	// layout is the layout of a time field, empty means the default of the Marshaler or Unmarshaler
+	layout string
+
+	// trueValue and falseValue are the tokens of a bool field,
+	// empty means the default of the Marshaler or Unmarshaler
+	trueValue, falseValue string
+
+	// implied is the number of implied decimals of a number, e.g. 0001234 is 12.34 with 2 implied decimals,
+	// hasImplied is false if the option is not set.
+	implied    int
+	hasImplied bool
+
+	// prec and format are the precision and format of a float, see strconv.FormatFloat,
+	// hasPrec is false if the precision is not set and format is zero if the format is not set.
+	prec    int
+	hasPrec bool
+	format  byte
+
+	// strict makes the encoding of a number fail if it is longer than the width, instead of truncating it
+	strict bool
+
+	// sign is the position of the sign of a number, plus makes positive numbers signed as well
+	sign signPosition
+	plus bool
+
+	// zoned encodes a number as a zoned decimal, the sign is overpunched in the last digit
+	zoned bool
+
+	// usage is the representation of a number, text unless comp or comp3 is set,
+	// unsigned makes a binary number unsigned
+	usage    usage
+	unsigned bool
+
+	// overflow is the handling of values longer than the field, the default of the Marshaler if it is not set
+	overflow Overflow
+
+	// control makes the field a control total of the records of its group, see controlTotal.
+	// controlArg is the summed field, or the codes of the counted records separated by |.
+	control    controlKind
+	controlArg string
+}
+
+// parse parses the `fixed` tag of a struct field,
+// a field without the tag has zero width.
+func (tag) parse(field reflect.StructField) (tagOptions, error) {
+	var opts tagOptions
 	t, ok := field.Tag.Lookup(tagName)
 	if !ok {
-		return 0, true
+		return opts, nil
 	}
 
-	l, err := strconv.ParseInt(t, 10, 64)
+	parts := strings.Split(t, ",")
+	if i := strings.IndexByte(parts[0], '-'); i > 0 {
+		start, err1 := strconv.Atoi(strings.TrimSpace(parts[0][:i]))
+		end, err2 := strconv.Atoi(strings.TrimSpace(parts[0][i+1:]))
+		if err1 != nil || err2 != nil || start < 1 || end < start {
+			return opts, fmt.Errorf("invalid columns %q", parts[0])
+		}
+		opts.start, opts.width = start, end-start+1
+	} else {
+		width, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil || width < 0 {
+			return opts, fmt.Errorf("invalid width %q", parts[0])
+		}
+		opts.width = int(width)
+	}
+
+	for _, option := range parts[1:] {
+		// the value is not trimmed, a space is a valid pad character
+		key, value, hasValue := option, "", false
+		if i := strings.IndexByte(option, '='); i >= 0 {
+			key, value, hasValue = option[:i], option[i+1:], true
+		}
+		key = strings.TrimSpace(key)
+
+		switch key {
+		case "left", "right", "center":
+			if hasValue {
+				return opts, fmt.Errorf("option %s does not take a value", key)
+			}
+			opts.align = alignments[key]
+		case "pad":
+			r, size := utf8.DecodeRuneInString(value)
+			if size == 0 || size != len(value) || r == utf8.RuneError {
+				return opts, fmt.Errorf("invalid pad %q, it must be a single character", value)
+			}
+			opts.pad = r
+		case "time":
+			if value == "" {
+				return opts, fmt.Errorf("empty time layout")
+			}
+			opts.layout = value
+		case "implied":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid implied decimals %q", value)
+			}
+			opts.implied, opts.hasImplied = n, true
+		case "prec":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < -1 {
+				return opts, fmt.Errorf("invalid precision %q", value)
+			}
+			opts.prec, opts.hasPrec = n, true
+		case "fmt":
+			switch value = strings.TrimSpace(value); value {
+			case "f", "e", "g":
+				opts.format = value[0]
+			default:
+				return opts, fmt.Errorf("invalid float format %q, it must be f, e or g", value)
+			}
+		case "strict", "plus", "zoned", "comp", "comp3", "unsigned":
+			if hasValue {
+				return opts, fmt.Errorf("option %s does not take a value", key)
+			}
+			switch key {
+			case "strict":
+				opts.strict = true
+			case "plus":
+				opts.plus = true
+			case "zoned":
+				opts.zoned = true
+			case "comp":
+				opts.usage = usageComp
+			case "comp3":
+				opts.usage = usageComp3
+			case "unsigned":
+				opts.unsigned = true
+			}
+		case "overflow":
+			o, ok := overflows[strings.TrimSpace(value)]
+			if !ok {
+				return opts, fmt.Errorf("invalid overflow %q, it must be truncate, error, left or mark", value)
+			}
+			opts.overflow = o
+		case "count":
+			opts.control, opts.controlArg = controlCount, strings.TrimSpace(value)
+			if hasValue && opts.controlArg == "" {
+				return opts, fmt.Errorf("empty record codes of option count")
+			}
+		case "sum", "hash":
+			if value = strings.TrimSpace(value); value == "" {
+				return opts, fmt.Errorf("option %s requires a field", key)
+			}
+			opts.control, opts.controlArg = controlSum, value
+			if key == "hash" {
+				opts.control = controlHash
+			}
+		case "sign":
+			p, ok := signPositions[strings.TrimSpace(value)]
+			if !ok {
+				return opts, fmt.Errorf("invalid sign %q, it must be leading, trailing, leading-separate or trailing-separate", value)
+			}
+			opts.sign = p
+		case "true", "false":
+			if value == "" {
+				return opts, fmt.Errorf("empty %s value", key)
+			}
+			if key == "true" {
+				opts.trueValue = value
+			} else {
+				opts.falseValue = value
+			}
+		default:
+			return opts, fmt.Errorf("unknown option %q", option)
+		}
+	}
+
+	// zoned decimals are filled with zeros unless the pad is set
+	if opts.zoned && opts.pad == 0 {
+		opts.pad = '0'
+	}
+
+	if opts.trueValue != "" && opts.trueValue == opts.falseValue {
+		return opts, fmt.Errorf("true and false values are both %q", opts.trueValue)
+	}
+
+	return opts, nil
+}
+
+// check reports the options which cannot be applied to a field of type t
+func (o tagOptions) check(t reflect.Type) error {
+	if (o.hasImplied || o.strict || o.hasSignOptions() || o.zoned) && !isNumeric(t) {
+		return fmt.Errorf("options implied, strict, sign, plus and zoned require a numeric field")
+	}
+	if o.zoned && (o.hasSignOptions() || o.format != 0 && o.format != 'f') {
+		return fmt.Errorf("option zoned cannot be combined with sign, plus or fmt")
+	}
+	if o.unsigned && o.usage == usageDisplay {
+		return fmt.Errorf("option unsigned requires comp or comp3")
+	}
+	if o.usage != usageDisplay {
+		if !isNumeric(t) {
+			return fmt.Errorf("options comp and comp3 require a numeric field")
+		}
+		if o.zoned || o.strict || o.hasSignOptions() || o.hasPrec || o.format != 0 {
+			return fmt.Errorf("options comp and comp3 cannot be combined with zoned, strict, sign, plus, prec or fmt")
+		}
+		if o.usage == usageComp && o.width > 8 {
+			return fmt.Errorf("a comp field has at most 8 bytes")
+		}
+	}
+	if o.sign.separate() && o.width < 2 {
+		return fmt.Errorf("a separate sign requires a width of at least 2")
+	}
+	if (o.hasPrec || o.format != 0) && !isFloat(t) {
+		return fmt.Errorf("options prec and fmt require a float field")
+	}
+	if o.control != controlNone && !isInteger(t) {
+		return fmt.Errorf("options count, sum and hash require an integer field")
+	}
+	if o.hasImplied && (o.hasPrec || o.format != 0) {
+		return fmt.Errorf("option implied cannot be combined with prec or fmt")
+	}
+	return nil
+}
+
+// getLimitFixedTag get the tag `fixed` of a struct field then convert to integer
+// if fixed tag is valid true will be returned; otherwise, false will be returned
+func (t tag) getLimitFixedTag(field reflect.StructField) (int, bool) {
+	opts, err := t.parse(field)
 	if err != nil {
-		ok = false
+		return 0, false
 	}
-	return int(l), ok
+	return opts.width, true
 }
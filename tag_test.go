@@ -14,6 +14,14 @@ func Test_tag_getLimitFixedTag(t *testing.T) {
 		Name string `fixed:"abc"`
 	}
 	var i invalidFixedTag
+	type fixedTagWithOptions struct {
+		Name string `fixed:"12,right"`
+	}
+	var o fixedTagWithOptions
+	type unknownOption struct {
+		Name string `fixed:"12,up"`
+	}
+	var u unknownOption
 
 	type args struct {
 		field reflect.StructField
@@ -40,6 +48,22 @@ func Test_tag_getLimitFixedTag(t *testing.T) {
 			want: 0,
 			ok:   false,
 		},
+		{
+			name: "with options",
+			args: args{
+				field: reflect.TypeOf(o).Field(0),
+			},
+			want: 12,
+			ok:   true,
+		},
+		{
+			name: "unknown option",
+			args: args{
+				field: reflect.TypeOf(u).Field(0),
+			},
+			want: 0,
+			ok:   false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -54,3 +78,89 @@ func Test_tag_getLimitFixedTag(t *testing.T) {
 		})
 	}
 }
+
+func Test_tag_parse(t *testing.T) {
+	type aligned struct {
+		Default string `fixed:"1"`
+		Left    string `fixed:"2,left"`
+		Right   string `fixed:"3, right"`
+		Center  string `fixed:"4,center"`
+		Zero    int    `fixed:"5,pad=0"`
+		Space   string `fixed:"6,right,pad= "`
+		Dot     string `fixed:"7,pad=·"`
+		Columns string `fixed:"15-24,right"`
+		Time    string `fixed:"8,time=20060102"`
+		Bool    bool   `fixed:"1,true=Y,false=N"`
+		Implied int    `fixed:"7,implied=2"`
+		Float   string `fixed:"9,prec=3,fmt=e,strict"`
+		Sign    int    `fixed:"5,sign=trailing-separate,plus"`
+		Zoned   int    `fixed:"9,zoned,implied=2"`
+		Comp3   int    `fixed:"4,comp3,unsigned"`
+		Mark    string `fixed:"4,overflow=mark"`
+		Count   int    `fixed:"6,count=6|7"`
+		Hash    int    `fixed:"10,hash=Routing"`
+	}
+
+	want := []tagOptions{
+		{width: 1, align: alignDefault},
+		{width: 2, align: alignLeft},
+		{width: 3, align: alignRight},
+		{width: 4, align: alignCenter},
+		{width: 5, pad: '0'},
+		{width: 6, align: alignRight, pad: ' '},
+		{width: 7, pad: '·'},
+		{width: 10, start: 15, align: alignRight},
+		{width: 8, layout: "20060102"},
+		{width: 1, trueValue: "Y", falseValue: "N"},
+		{width: 7, implied: 2, hasImplied: true},
+		{width: 9, prec: 3, hasPrec: true, format: 'e', strict: true},
+		{width: 5, sign: signTrailingSeparate, plus: true},
+		{width: 9, pad: '0', implied: 2, hasImplied: true, zoned: true},
+		{width: 4, usage: usageComp3, unsigned: true},
+		{width: 4, overflow: OverflowMark},
+		{width: 6, control: controlCount, controlArg: "6|7"},
+		{width: 10, control: controlHash, controlArg: "Routing"},
+	}
+	typ := reflect.TypeOf(aligned{})
+	for i, w := range want {
+		got, err := tag{}.parse(typ.Field(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("parse() %s got = %+v, want %+v", typ.Field(i).Name, got, w)
+		}
+	}
+}
+
+func Test_tag_parse_Errors(t *testing.T) {
+	type invalid struct {
+		Width    string `fixed:"-1"`
+		Empty    string `fixed:""`
+		AlignArg string `fixed:"1,left=2"`
+		NoPad    string `fixed:"1,pad="`
+		LongPad  string `fixed:"1,pad=ab"`
+		Column0  string `fixed:"0-3"`
+		Reversed string `fixed:"5-3"`
+		NoEnd    string `fixed:"5-"`
+		NoLayout string `fixed:"8,time="`
+		NoTrue   bool   `fixed:"1,true="`
+		SameBool bool   `fixed:"1,true=Y,false=Y"`
+		Implied  int    `fixed:"7,implied=-1"`
+		Prec     string `fixed:"7,prec=x"`
+		Format   string `fixed:"7,fmt=x"`
+		Strict   string `fixed:"7,strict=1"`
+		Sign     int    `fixed:"7,sign=middle"`
+		Zoned    int    `fixed:"7,zoned=1"`
+		Overflow string `fixed:"7,overflow=wrap"`
+		Sum      int    `fixed:"7,sum="`
+		Count    int    `fixed:"7,count="`
+	}
+
+	typ := reflect.TypeOf(invalid{})
+	for i := 0; i < typ.NumField(); i++ {
+		if _, err := (tag{}).parse(typ.Field(i)); err == nil {
+			t.Errorf("parse() %s expected error", typ.Field(i).Name)
+		}
+	}
+}
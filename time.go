@@ -0,0 +1,69 @@
+package fixedwidth
+
+import (
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// defaultTimeLayout is used when neither the field nor the Marshaler or Unmarshaler sets a layout
+const defaultTimeLayout = time.RFC3339
+
+// timeLayout returns the layout of a time field,
+// fieldLayout is the time option of the fixed tag, empty if it is not set.
+func timeLayout(fieldLayout, layout string) string {
+	if fieldLayout != "" {
+		return fieldLayout
+	}
+	if layout != "" {
+		return layout
+	}
+	return defaultTimeLayout
+}
+
+// encodeTime formats the time in the location of the Marshaler,
+// the zero time is left blank.
+func encodeTime(m *Marshaler, f *fieldPlan, v reflect.Value) error {
+	t := v.Interface().(time.Time)
+	if t.IsZero() {
+		return nil
+	}
+
+	if m.TimeLocation != nil {
+		t = t.In(m.TimeLocation)
+	}
+	m.b = t.AppendFormat(m.b, timeLayout(f.layout, m.TimeLayout))
+	return nil
+}
+
+// decodeTime parses the time in the location of the Unmarshaler,
+// a field which is blank or only holds zeros is the zero time.
+func decodeTime(d *decodeState, f *fieldPlan, data []byte, v reflect.Value) error {
+	data = d.removeFieldPadding(f, data)
+	if isZeros(data) {
+		v.Set(reflect.Zero(timeType))
+		return nil
+	}
+
+	loc := d.TimeLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(timeLayout(f.layout, d.TimeLayout), string(data), loc)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// isZeros reports whether data is empty or only holds zeros and spaces
+func isZeros(data []byte) bool {
+	for _, c := range data {
+		if c != '0' && c != spaceByte {
+			return false
+		}
+	}
+	return true
+}
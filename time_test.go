@@ -0,0 +1,135 @@
+package fixedwidth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type timeRecord struct {
+	Date     time.Time  `fixed:"8,time=20060102"`
+	Clock    time.Time  `fixed:"4,time=1504"`
+	Created  time.Time  `fixed:"14"`
+	Deadline *time.Time `fixed:"8,time=20060102,pad=0"`
+}
+
+func TestMarshal_Time(t *testing.T) {
+	hcm := time.FixedZone("ICT", 7*60*60)
+	deadline := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		v        timeRecord
+		want     string
+	}{
+		{
+			name: "layouts",
+			v: timeRecord{
+				Date:     time.Date(2019, 12, 12, 0, 0, 0, 0, time.UTC),
+				Clock:    time.Date(0, 1, 1, 9, 30, 0, 0, time.UTC),
+				Created:  time.Date(2019, 12, 12, 23, 15, 1, 0, time.UTC),
+				Deadline: &deadline,
+			},
+			want: "20191212093020191212231501" + "20200131",
+		},
+		{
+			name:     "location",
+			location: hcm,
+			v: timeRecord{
+				Created: time.Date(2019, 12, 12, 23, 15, 1, 0, time.UTC),
+			},
+			want: "            " + "20191213061501" + "00000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.TimeLayout = "20060102150405"
+			m.TimeLocation = tt.location
+			got, err := m.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Time(t *testing.T) {
+	hcm := time.FixedZone("ICT", 7*60*60)
+	deadline := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		data     string
+		want     timeRecord
+	}{
+		{
+			name: "layouts",
+			data: "20191212093020191212231501" + "20200131",
+			want: timeRecord{
+				Date:     time.Date(2019, 12, 12, 0, 0, 0, 0, time.UTC),
+				Clock:    time.Date(0, 1, 1, 9, 30, 0, 0, time.UTC),
+				Created:  time.Date(2019, 12, 12, 23, 15, 1, 0, time.UTC),
+				Deadline: &deadline,
+			},
+		},
+		{
+			name:     "blank and zeros",
+			location: hcm,
+			data:     "00000000    " + "20191213061501" + "00000000",
+			want: timeRecord{
+				Created:  time.Date(2019, 12, 13, 6, 15, 1, 0, hcm),
+				Deadline: &time.Time{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewUnmarshaler()
+			u.TimeLayout = "20060102150405"
+			u.TimeLocation = tt.location
+			var got timeRecord
+			if err := u.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var got timeRecord
+	if err := Unmarshal([]byte("20191332"), &got); err == nil {
+		t.Error("Unmarshal() expected error for an invalid date")
+	}
+}
+
+func TestTime_DefaultLayout(t *testing.T) {
+	type record struct {
+		At time.Time `fixed:"20"`
+	}
+
+	v := record{At: time.Date(2019, 12, 12, 23, 15, 1, 0, time.UTC)}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2019-12-12T23:15:01Z"; string(got) != want {
+		t.Errorf("Marshal() got = %q, want %q", got, want)
+	}
+
+	var back record
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.At.Equal(v.At) {
+		t.Errorf("Unmarshal() got = %v, want %v", back.At, v.At)
+	}
+}
@@ -0,0 +1,190 @@
+package fixedwidth
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// WidthMode is the unit of the width of fields
+type WidthMode int
+
+const (
+	// Runes counts the Unicode characters of a field, it is the default
+	Runes WidthMode = iota
+
+	// Bytes counts the bytes of the UTF-8 encoding of a field
+	Bytes
+
+	// DisplayCells counts the cells taken by a field on a terminal,
+	// East Asian wide characters take two cells and combining marks none.
+	DisplayCells
+)
+
+// runeWidth returns the width of r, which UTF-8 encoding has size bytes
+func (w WidthMode) runeWidth(r rune, size int) int {
+	switch w {
+	case Bytes:
+		return size
+	case DisplayCells:
+		return displayWidth(r)
+	}
+	return 1
+}
+
+// width returns the width of the UTF-8 text b
+func (w WidthMode) width(b []byte) int {
+	switch w {
+	case Runes:
+		return utf8.RuneCount(b)
+	case Bytes:
+		return len(b)
+	}
+
+	n := 0
+	for len(b) > 0 {
+		r, s := utf8.DecodeRune(b)
+		n += w.runeWidth(r, s)
+		b = b[s:]
+	}
+	return n
+}
+
+// prefix returns the length in bytes and the width of the longest prefix of b which is not wider than limit,
+// it never splits a character.
+func (w WidthMode) prefix(b []byte, limit int) (n, width int) {
+	if w == Runes {
+		for n < len(b) && width < limit {
+			if b[n] < utf8.RuneSelf {
+				n++
+			} else {
+				_, s := utf8.DecodeRune(b[n:])
+				n += s
+			}
+			width++
+		}
+		return n, width
+	}
+
+	for n < len(b) {
+		r, s := utf8.DecodeRune(b[n:])
+		rw := w.runeWidth(r, s)
+		if width+rw > limit {
+			break
+		}
+		n += s
+		width += rw
+	}
+	return n, width
+}
+
+// suffix returns the length in bytes of the longest suffix of b which is not wider than limit,
+// it never splits a character.
+func (w WidthMode) suffix(b []byte, limit int) int {
+	n, width := 0, 0
+	for n < len(b) {
+		r, s := utf8.DecodeLastRune(b[:len(b)-n])
+		rw := w.runeWidth(r, s)
+		if width+rw > limit {
+			break
+		}
+		n += s
+		width += rw
+	}
+	return n
+}
+
+// upperBound returns the end of the field of the given width starting at lowerBound in data
+func (w WidthMode) upperBound(lowerBound, limit int, data []byte) int {
+	if lowerBound >= len(data) {
+		return len(data)
+	}
+	if w == Bytes {
+		if lowerBound+limit > len(data) {
+			return len(data)
+		}
+		return lowerBound + limit
+	}
+
+	n, _ := w.prefix(data[lowerBound:], limit)
+	return lowerBound + n
+}
+
+// displayWidth returns the number of terminal cells taken by r
+func displayWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case unicode.Is(wideRunes, r):
+		return 2
+	}
+	return 1
+}
+
+// wideRunes are the East Asian wide and fullwidth characters, including emoji presentation characters
+var wideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115F, Stride: 1},
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},
+		{Lo: 0x2329, Hi: 0x232A, Stride: 1},
+		{Lo: 0x23E9, Hi: 0x23EC, Stride: 1},
+		{Lo: 0x23F0, Hi: 0x23F0, Stride: 1},
+		{Lo: 0x23F3, Hi: 0x23F3, Stride: 1},
+		{Lo: 0x25FD, Hi: 0x25FE, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267F, Hi: 0x267F, Stride: 1},
+		{Lo: 0x2693, Hi: 0x2693, Stride: 1},
+		{Lo: 0x26A1, Hi: 0x26A1, Stride: 1},
+		{Lo: 0x26AA, Hi: 0x26AB, Stride: 1},
+		{Lo: 0x26BD, Hi: 0x26BE, Stride: 1},
+		{Lo: 0x26C4, Hi: 0x26C5, Stride: 1},
+		{Lo: 0x26CE, Hi: 0x26CE, Stride: 1},
+		{Lo: 0x26D4, Hi: 0x26D4, Stride: 1},
+		{Lo: 0x26EA, Hi: 0x26EA, Stride: 1},
+		{Lo: 0x26F2, Hi: 0x26F3, Stride: 1},
+		{Lo: 0x26F5, Hi: 0x26F5, Stride: 1},
+		{Lo: 0x26FA, Hi: 0x26FA, Stride: 1},
+		{Lo: 0x26FD, Hi: 0x26FD, Stride: 1},
+		{Lo: 0x2705, Hi: 0x2705, Stride: 1},
+		{Lo: 0x270A, Hi: 0x270B, Stride: 1},
+		{Lo: 0x2728, Hi: 0x2728, Stride: 1},
+		{Lo: 0x274C, Hi: 0x274C, Stride: 1},
+		{Lo: 0x274E, Hi: 0x274E, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2757, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27B0, Hi: 0x27B0, Stride: 1},
+		{Lo: 0x27BF, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},
+		{Lo: 0x2B50, Hi: 0x2B50, Stride: 1},
+		{Lo: 0x2B55, Hi: 0x2B55, Stride: 1},
+		{Lo: 0x2E80, Hi: 0x303E, Stride: 1},
+		{Lo: 0x3041, Hi: 0x33FF, Stride: 1},
+		{Lo: 0x3400, Hi: 0x4DBF, Stride: 1},
+		{Lo: 0x4E00, Hi: 0x9FFF, Stride: 1},
+		{Lo: 0xA000, Hi: 0xA4CF, Stride: 1},
+		{Lo: 0xA960, Hi: 0xA97F, Stride: 1},
+		{Lo: 0xAC00, Hi: 0xD7A3, Stride: 1},
+		{Lo: 0xF900, Hi: 0xFAFF, Stride: 1},
+		{Lo: 0xFE10, Hi: 0xFE19, Stride: 1},
+		{Lo: 0xFE30, Hi: 0xFE6F, Stride: 1},
+		{Lo: 0xFF00, Hi: 0xFF60, Stride: 1},
+		{Lo: 0xFFE0, Hi: 0xFFE6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x16FE0, Hi: 0x16FE4, Stride: 1},
+		{Lo: 0x17000, Hi: 0x18CFF, Stride: 1},
+		{Lo: 0x1B000, Hi: 0x1B2FF, Stride: 1},
+		{Lo: 0x1F004, Hi: 0x1F004, Stride: 1},
+		{Lo: 0x1F0CF, Hi: 0x1F0CF, Stride: 1},
+		{Lo: 0x1F18E, Hi: 0x1F18E, Stride: 1},
+		{Lo: 0x1F191, Hi: 0x1F19A, Stride: 1},
+		{Lo: 0x1F200, Hi: 0x1F2FF, Stride: 1},
+		{Lo: 0x1F300, Hi: 0x1F64F, Stride: 1},
+		{Lo: 0x1F680, Hi: 0x1F6FF, Stride: 1},
+		{Lo: 0x1F7E0, Hi: 0x1F7EB, Stride: 1},
+		{Lo: 0x1F900, Hi: 0x1F9FF, Stride: 1},
+		{Lo: 0x1FA70, Hi: 0x1FAFF, Stride: 1},
+		{Lo: 0x20000, Hi: 0x3FFFD, Stride: 1},
+	},
+}
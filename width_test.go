@@ -0,0 +1,97 @@
+package fixedwidth
+
+import (
+	"reflect"
+	"testing"
+)
+
+type report struct {
+	Name  string `fixed:"6"`
+	City  string `fixed:"5,right"`
+	Note  string `fixed:"4,center,pad=·"`
+	Count int    `fixed:"3,pad=0"`
+}
+
+func TestWidthMode(t *testing.T) {
+	tests := []struct {
+		name string
+		mode WidthMode
+		v    report
+		data string
+		want report
+	}{
+		{
+			name: "runes",
+			mode: Runes,
+			v:    report{Name: "東京都庁舎ビル", City: "Hà", Note: "ok", Count: 7},
+			data: "東京都庁舎ビ   Hà·ok·007",
+			want: report{Name: "東京都庁舎ビ", City: "Hà", Note: "ok", Count: 7},
+		},
+		{
+			name: "bytes",
+			mode: Bytes,
+			v:    report{Name: "東京都", City: "Hà", Note: "abcdef", Count: 7},
+			data: "東京" + "  Hà" + "abcd" + "007",
+			want: report{Name: "東京", City: "Hà", Note: "abcd", Count: 7},
+		},
+		{
+			name: "display cells",
+			mode: DisplayCells,
+			v:    report{Name: "東京都庁", City: "Hà", Note: "日本", Count: 7},
+			data: "東京都" + "   Hà" + "日本" + "007",
+			want: report{Name: "東京都", City: "Hà", Note: "日本", Count: 7},
+		},
+		{
+			name: "wide glyph cut",
+			mode: DisplayCells,
+			v:    report{Name: "abc東京", City: "中文", Note: "x", Count: 7},
+			data: "abc東 " + " 中文" + "·x··" + "007",
+			want: report{Name: "abc東", City: "中文", Note: "x", Count: 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMarshaler()
+			m.WidthMode = tt.mode
+			got, err := m.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.data {
+				t.Errorf("Marshal() got = %q, want %q", got, tt.data)
+			}
+
+			u := NewUnmarshaler()
+			u.WidthMode = tt.mode
+			var back report
+			if err := u.Unmarshal([]byte(tt.data), &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", back, tt.want)
+			}
+		})
+	}
+}
+
+func Test_displayWidth(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want int
+	}{
+		{'a', 1},
+		{'é', 1},
+		{'́', 0},
+		{'中', 2},
+		{'한', 2},
+		{'Ａ', 2},
+		{'😀', 2},
+	}
+
+	for _, tt := range tests {
+		if got := displayWidth(tt.r); got != tt.want {
+			t.Errorf("displayWidth(%q) got = %d, want %d", tt.r, got, tt.want)
+		}
+	}
+}